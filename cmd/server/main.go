@@ -7,6 +7,8 @@ import (
 	core2 "plane_war/internal/core"
 	"plane_war/internal/global"
 	"plane_war/internal/router"
+	"plane_war/internal/service/game"
+	"plane_war/internal/service/janitor"
 )
 
 type Options struct {
@@ -22,6 +24,12 @@ func main() {
 	global.DB = core2.InitGorm(global.Config.Mysql.Dsn)
 	//redis连接
 	global.Redis = core2.InitRedis(global.Config.Redis.Addr, global.Config.Redis.Pwd, global.Config.Redis.DB)
+	//加载武器、道具等游戏数据
+	if err := game.LoadGameData("./internal/etc"); err != nil {
+		log.Fatal(err)
+	}
+	//定期清理大厅房间
+	janitor.Start()
 	r := router.InitRouter()
 	global.Log.Info(global.Config.Server.Host + global.Config.Server.Port)
 	if err := r.Run(global.Config.Server.Port); err != nil {
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"plane_war/internal/model/res"
+	"plane_war/internal/service/game"
+)
+
+// GetGameRooms 进行中的对局房间列表
+func GetGameRooms(c *gin.Context) {
+	rooms := game.ListRooms()
+	res.OkWithList(rooms, int64(len(rooms)), c)
+}
+
+// GetGameRoom 查询单个对局房间的状态
+func GetGameRoom(c *gin.Context) {
+	info, ok := game.GetRoomInfo(c.Query("id"))
+	if !ok {
+		res.FailWithMsg("房间不存在或已结束", c)
+		return
+	}
+	res.OkWithData(info, c)
+}
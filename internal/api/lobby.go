@@ -1,23 +1,35 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
+	"plane_war/internal/global"
 	"plane_war/internal/model"
 	"plane_war/internal/model/ctype"
 	"plane_war/internal/model/res"
 	"plane_war/internal/service/game"
 	"plane_war/internal/service/redis_service"
 	"plane_war/internal/utils/jwts"
+	"plane_war/internal/utils/pwd"
 	"plane_war/internal/ws"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	// 房间密码长度范围
+	minRoomPassword = 4
+	maxRoomPassword = 32
+	// inviteExpire 邀请的有效期
+	inviteExpire = 30 * time.Minute
+	// 房间列表每页数量
+	defaultRoomPage = 20
+	maxRoomPage     = 50
+)
+
 // 创建公共房间
 func CreatePublicRoom(c *gin.Context) {
 	// 解析 token
@@ -25,12 +37,44 @@ func CreatePublicRoom(c *gin.Context) {
 	claims := _cliams.(*jwts.CustomClaims)
 
 	var req struct {
-		Capacity int `json:"capacity" binding:"required"`
+		// RoomSettings 模式、场地、人数等设置与其他字段平铺
+		model.RoomSettings
+		AutoStart bool `json:"auto_start"` // 全员准备后自动开始
+		// Visibility 默认 public；password 房间需要同时提供 Password
+		Visibility ctype.RoomVisibility `json:"visibility"`
+		Password   string               `json:"password"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		res.FailWithMsg("参数错误", c)
 		return
 	}
+	if err := game.ValidateSettings(&req.RoomSettings); err != nil {
+		res.FailWithMsg(err.Error(), c)
+		return
+	}
+	if req.Name == "" {
+		req.Name = claims.Nickname + "的房间"
+	}
+	if req.Visibility == "" {
+		req.Visibility = ctype.VisibilityPublic
+	}
+	if !req.Visibility.Valid() {
+		res.FailWithMsg("房间可见性不合法", c)
+		return
+	}
+	var passwordHash string
+	if req.Visibility == ctype.VisibilityPassword {
+		if len(req.Password) < minRoomPassword || len(req.Password) > maxRoomPassword {
+			res.FailWithMsg(fmt.Sprintf("房间密码长度需为 %d~%d 位", minRoomPassword, maxRoomPassword), c)
+			return
+		}
+		hash, err := pwd.HashPassword(req.Password)
+		if err != nil {
+			res.FailWithMsg("设置房间密码失败", c)
+			return
+		}
+		passwordHash = hash
+	}
 
 	player := &model.Player{
 		UserID: claims.UserID,
@@ -38,71 +82,197 @@ func CreatePublicRoom(c *gin.Context) {
 	}
 	fmt.Println(player)
 	room := &model.PublicRoom{
-		ID:       uuid.New().String(),
-		OwnerID:  claims.UserID,
-		Code:     uuid.New().String()[:6], // 房间码取前6位
-		Players:  []*model.Player{player},
-		Capacity: req.Capacity,
-		Status:   ctype.Waiting,
-		Created:  time.Now(),
+		ID:           uuid.New().String(),
+		OwnerID:      claims.UserID,
+		Players:      []*model.Player{player},
+		RoomSettings: req.RoomSettings,
+		AutoStart:    req.AutoStart,
+		Visibility:   req.Visibility,
+		Status:       ctype.Waiting,
+		Created:      time.Now(),
 	}
 
+	// 先离开之前的房间并通知其成员，保存时会再检查一次
+	leaveCurrentRoom(claims.UserID)
+
 	// 保存到 Redis（自动会删除之前的房间并绑定 userID）
-	if err := redis_service.SavePublicRoomToRedis(room, claims.UserID); err != nil {
+	if err := redis_service.SavePublicRoomToRedis(room, claims.UserID, passwordHash); err != nil {
 		res.FailWithMsg(fmt.Sprintf("创建房间失败: %v", err), c)
 		return
 	}
+	ws.PublishRoomListUpdate(room)
 
 	res.OkWithData(room, c)
 }
 
-// 获取房间列表
+// 获取房间列表，支持筛选、搜索与游标分页。
+// 返回的 count 只在没有筛选条件与关键词时统计，否则为 -1 表示未统计，是否还有下一页以 next 为准
 func GetPublicRooms(c *gin.Context) {
-	rooms, err := redis_service.GetPublicRoomsList()
+	var req struct {
+		Status  ctype.RoomStatus `form:"status"`  // 1 等待中，2 游戏中
+		Mode    ctype.GameMode   `form:"mode"`    // pvp / coop
+		Free    bool             `form:"free"`    // 只看有空位的房间
+		Friends string           `form:"friends"` // 好友的用户ID，逗号分隔，只看有好友在内的房间
+		Keyword string           `form:"keyword"` // 房间名或房间码
+		Cursor  string           `form:"cursor"`  // 上一页返回的 next
+		Limit   int              `form:"limit"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		res.FailWithMsg("参数错误", c)
+		return
+	}
+	if req.Limit <= 0 || req.Limit > maxRoomPage {
+		req.Limit = defaultRoomPage
+	}
+	query := redis_service.RoomQuery{
+		Status:  req.Status,
+		Mode:    req.Mode,
+		Free:    req.Free,
+		Keyword: strings.TrimSpace(req.Keyword),
+		Cursor:  req.Cursor,
+		Limit:   req.Limit,
+	}
+	for _, s := range strings.Split(req.Friends, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64); err == nil {
+			query.Friends = append(query.Friends, uint(id))
+		}
+	}
+
+	rooms, next, total, err := redis_service.QueryPublicRooms(query)
 	if err != nil {
 		res.FailWithMsg(fmt.Sprintf("获取房间列表失败: %v", err), c)
 		return
 	}
-	res.OkWithData(rooms, c)
+	// 已开局的房间附带对局状态，对局房间与公共房间共用ID
+	for _, r := range rooms {
+		if info, ok := game.GetRoomInfo(r.ID); ok {
+			r.Game = info.Status
+		}
+	}
+	res.OkWithPage(rooms, total, next, c)
 }
 
-// 加入公共房间
+// 加入公共房间，凭房间码（密码房间还需密码）或好友分享的邀请加入
 func JoinPublicRoom(c *gin.Context) {
 	// 解析 token
 	_cliams, _ := c.Get("claims")
 	claims := _cliams.(*jwts.CustomClaims)
 	fmt.Println(claims.UserID)
 	var req struct {
-		RoomCode string `json:"room_code" binding:"required"`
+		RoomCode string `json:"room_code"`
+		Password string `json:"password"`
+		Invite   string `json:"invite"` // 邀请链接中的 invite 参数，持有时无需密码
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		res.FailWithMsg("参数错误", c)
 		return
 	}
+	var invite *jwts.InviteClaims
+	if req.Invite != "" {
+		var err error
+		invite, err = jwts.ParseInviteToken(req.Invite)
+		if err != nil {
+			res.FailWithMsg("邀请无效或已过期", c)
+			return
+		}
+		req.RoomCode = invite.RoomCode
+	}
+	if strings.TrimSpace(req.RoomCode) == "" {
+		res.FailWithMsg("参数错误", c)
+		return
+	}
+
+	target, err := redis_service.FindRoomByInput(req.RoomCode)
+	if err != nil {
+		res.FailWithMsg(fmt.Sprintf("加入房间失败: %v", err), c)
+		return
+	}
+	// 房间码会在房间删除后重新分配，邀请只对生成时的那个房间有效
+	if invite != nil && invite.RoomID != target.ID {
+		res.FailWithMsg("邀请无效或已过期", c)
+		return
+	}
+	if invite == nil {
+		if err := redis_service.CheckRoomPassword(target, req.Password); err != nil {
+			res.FailWithMsg(err.Error(), c)
+			return
+		}
+	}
 
 	player := &model.Player{
 		UserID: claims.UserID,
 		Name:   claims.Nickname,
 	}
 
-	if err := redis_service.AddPlayerToRoom(req.RoomCode, player); err != nil {
+	room, err := redis_service.AddPlayerToRoom(target.Code, player)
+	if err != nil {
 		res.FailWithMsg(fmt.Sprintf("加入房间失败: %v", err), c)
 		return
 	}
+	ws.PublishLobbyEvent(room, ws.MemberJoined, claims.UserID)
 
 	res.OkWithMsg("加入房间成功", c)
 }
 
+// CreateInvite 房间成员生成邀请，好友通过邀请链接可直接加入
+func CreateInvite(c *gin.Context) {
+	_cliams, _ := c.Get("claims")
+	claims := _cliams.(*jwts.CustomClaims)
+
+	roomCode, err := redis_service.GetUserRoomCode(claims.UserID)
+	if err != nil {
+		res.FailWithMsg("不在任何房间中", c)
+		return
+	}
+	room, err := redis_service.GetPublicRoomByCode(roomCode)
+	if err != nil {
+		res.FailWithMsg(fmt.Sprintf("生成邀请失败: %v", err), c)
+		return
+	}
+	token, err := jwts.GenInviteToken(room.Code, room.ID, claims.UserID, inviteExpire)
+	if err != nil {
+		res.FailWithMsg("生成邀请失败", c)
+		return
+	}
+	// 前端把 invite 拼到分享链接中，打开链接时带上它调用 join_room
+	res.OkWithData(map[string]any{
+		"invite":     token,
+		"expires_at": time.Now().Add(inviteExpire).UnixMilli(),
+	}, c)
+}
+
+// LeavePublicRoom 离开当前所在的房间，房主离开时转让房主，最后一人离开时房间删除
+func LeavePublicRoom(c *gin.Context) {
+	_cliams, _ := c.Get("claims")
+	claims := _cliams.(*jwts.CustomClaims)
+
+	roomCode, err := redis_service.GetUserRoomCode(claims.UserID)
+	if err != nil {
+		res.FailWithMsg("不在任何房间中", c)
+		return
+	}
+	room, err := redis_service.RemovePlayerFromRoom(roomCode, claims.UserID)
+	if err != nil {
+		res.FailWithMsg(fmt.Sprintf("离开房间失败: %v", err), c)
+		return
+	}
+	ws.PublishLobbyEvent(room, ws.MemberLeft, claims.UserID)
+
+	res.OkWithMsg("已离开房间", c)
+}
+
 // DismissPublicRoom 解散房间
 func DismissPublicRoom(c *gin.Context) {
 	_cliams, _ := c.Get("claims")
 	claims := _cliams.(*jwts.CustomClaims)
 	fmt.Println(claims.UserID)
 	// 调用解散逻辑
-	if err := redis_service.DismissPublicRoom(claims.UserID); err != nil {
+	room, err := redis_service.DismissPublicRoom(claims.UserID)
+	if err != nil {
 		res.FailWithMsg(fmt.Sprintf("解散房间失败: %v", err), c)
 		return
 	}
+	ws.PublishLobbyEvent(room, ws.Dismissed, claims.UserID)
 
 	res.OkWithMsg("房间解散成功", c)
 }
@@ -123,21 +293,175 @@ func StartGame(c *gin.Context) {
 		res.FailWithMsg("获取房间失败", c)
 		return
 	}
+	// 成员也绑定了房间，开始游戏仅限房主
+	if room.OwnerID != user.UserID {
+		res.FailWithMsg("只有房主可以开始游戏", c)
+		return
+	}
+
+	if err := startPublicRoom(room); err != nil {
+		res.FailWithMsg(err.Error(), c)
+		return
+	}
+
+	res.OkWithMsg("游戏已开始", c)
+}
+
+// ToggleReady 成员切换准备状态
+func ToggleReady(c *gin.Context) {
+	_cliams, _ := c.Get("claims")
+	claims := _cliams.(*jwts.CustomClaims)
+
+	roomCode, err := redis_service.GetUserRoomCode(claims.UserID)
+	if err != nil {
+		res.FailWithMsg("不在任何房间中", c)
+		return
+	}
+	room, err := redis_service.ToggleReady(roomCode, claims.UserID)
+	if err != nil {
+		res.FailWithMsg(fmt.Sprintf("准备失败: %v", err), c)
+		return
+	}
+	ws.PublishLobbyEvent(room, ws.ReadyChanged, claims.UserID)
+	if room.AutoStartAt != 0 {
+		scheduleAutoStart(roomCode, room.AutoStartAt)
+	}
+	res.OkWithData(room, c)
+}
+
+// KickMember 房主将成员移出房间，ban 为 true 时禁止其再次加入
+func KickMember(c *gin.Context) {
+	_cliams, _ := c.Get("claims")
+	claims := _cliams.(*jwts.CustomClaims)
 
-	if len(room.Players) != room.Capacity {
-		res.FailWithMsg(fmt.Sprintf("房间人数不合法，当前人数 %d", len(room.Players)), c)
+	var req struct {
+		UserID uint `json:"user_id" binding:"required"`
+		Ban    bool `json:"ban"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.FailWithMsg("参数错误", c)
 		return
 	}
+	room, err := redis_service.KickPlayer(claims.UserID, req.UserID, req.Ban)
+	if err != nil {
+		res.FailWithMsg(fmt.Sprintf("移出玩家失败: %v", err), c)
+		return
+	}
+	ws.PublishLobbyEvent(room, ws.MemberKicked, req.UserID)
+	res.OkWithMsg("已移出玩家", c)
+}
+
+// LockPublicRoom 房主锁定或解锁房间
+func LockPublicRoom(c *gin.Context) {
+	_cliams, _ := c.Get("claims")
+	claims := _cliams.(*jwts.CustomClaims)
+
+	var req struct {
+		Locked bool `json:"locked"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.FailWithMsg("参数错误", c)
+		return
+	}
+	room, err := redis_service.SetRoomLocked(claims.UserID, req.Locked)
+	if err != nil {
+		res.FailWithMsg(fmt.Sprintf("锁定房间失败: %v", err), c)
+		return
+	}
+	ws.PublishLobbyEvent(room, ws.LockChanged, claims.UserID)
+	res.OkWithData(room, c)
+}
+
+// TransferOwner 房主将房主身份转让给其他成员
+func TransferOwner(c *gin.Context) {
+	_cliams, _ := c.Get("claims")
+	claims := _cliams.(*jwts.CustomClaims)
+
+	var req struct {
+		UserID uint `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.FailWithMsg("参数错误", c)
+		return
+	}
+	room, err := redis_service.TransferOwner(claims.UserID, req.UserID)
+	if err != nil {
+		res.FailWithMsg(fmt.Sprintf("转让房主失败: %v", err), c)
+		return
+	}
+	ws.PublishLobbyEvent(room, ws.OwnerChanged, req.UserID)
+	res.OkWithData(room, c)
+}
+
+// UpdatePublicRoom 房主在等待中修改房间设置，未提供的字段保持不变，所有成员需要重新准备
+func UpdatePublicRoom(c *gin.Context) {
+	_cliams, _ := c.Get("claims")
+	claims := _cliams.(*jwts.CustomClaims)
+
+	roomCode, err := redis_service.GetUserRoomCode(claims.UserID)
+	if err != nil {
+		res.FailWithMsg("不在任何房间中", c)
+		return
+	}
+	current, err := redis_service.GetPublicRoomByCode(roomCode)
+	if err != nil {
+		res.FailWithMsg(fmt.Sprintf("修改房间失败: %v", err), c)
+		return
+	}
+	settings := current.RoomSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		res.FailWithMsg("参数错误", c)
+		return
+	}
+	if err := game.ValidateSettings(&settings); err != nil {
+		res.FailWithMsg(err.Error(), c)
+		return
+	}
+	room, err := redis_service.UpdateRoomSettings(claims.UserID, settings)
+	if err != nil {
+		res.FailWithMsg(fmt.Sprintf("修改房间失败: %v", err), c)
+		return
+	}
+	ws.PublishLobbyEvent(room, ws.SettingsChanged, claims.UserID)
+	res.OkWithMsg("修改成功，成员需重新准备", c)
+}
+
+// scheduleAutoStart 到达预定时间后开始游戏，期间有人取消准备或房间变化则放弃
+func scheduleAutoStart(code string, startAt int64) {
+	time.AfterFunc(time.Until(time.UnixMilli(startAt)), func() {
+		room, err := redis_service.GetPublicRoomByCode(code)
+		if err != nil || room.AutoStartAt != startAt || !redis_service.AllReady(room) {
+			return
+		}
+		if err := startPublicRoom(room); err != nil {
+			global.Log.Warnf("房间 %s 自动开始失败: %v", code, err)
+		}
+	})
+}
+
+// startPublicRoom 先把大厅房间标记为游戏中占住开局，再用房间成员创建对局，创建失败时恢复为等待中
+func startPublicRoom(room *model.PublicRoom) (err error) {
+	room, err = redis_service.ClaimRoomStart(room.Code)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			resetLobbyStatus(room.Code, ws.ReadyChanged)
+		}
+	}()
+	if game.GetRoom(room.ID) != nil {
+		return fmt.Errorf("游戏已在进行中")
+	}
 
 	var gamePlayers []*model.Player
 	for _, p := range room.Players {
 		client := ws.FindClientByUserID(p.UserID)
 		if client == nil {
-			res.FailWithMsg(fmt.Sprintf("玩家 %s 未连接", p.Name), c)
-			return
+			return fmt.Errorf("玩家 %s 未连接", p.Name)
 		}
-		p.Conn = client.Player.Conn
-		gamePlayers = append(gamePlayers, p)
+		// 使用连接上的玩家对象，之后的操作才能作用到房间内的玩家
+		gamePlayers = append(gamePlayers, client.Player)
 	}
 
 	gameRoom := &model.Room{
@@ -148,35 +472,52 @@ func StartGame(c *gin.Context) {
 		Quit:    make(chan bool),
 	}
 
-	gameRoom.Lock.Lock()
-	gameRoom.Players[0].X = 100
-	gameRoom.Players[0].Y = 50
-	gameRoom.Players[0].HP = 100
-	gameRoom.Players[0].Position = "top"
-
-	gameRoom.Players[1].X = 100
-	gameRoom.Players[1].Y = 500
-	gameRoom.Players[1].HP = 100
-	gameRoom.Players[1].Position = "bottom"
-	gameRoom.Lock.Unlock()
+	if err := game.ApplySettings(gameRoom, room.RoomSettings); err != nil {
+		return err
+	}
+	game.InitRoom(gameRoom)
+	ws.PublishLobbyEvent(room, ws.GameStarting, room.OwnerID)
 
 	state := map[string]interface{}{
 		"type":    "match_success",
 		"room_id": gameRoom.ID,
 		"players": gameRoom.Players,
+		"arena":   game.ArenaOf(gameRoom),
 	}
-	data, _ := json.Marshal(state)
-	for _, p := range gameRoom.Players {
-		p.Conn.WriteMessage(websocket.TextMessage, data)
-	}
+	ws.SendToRoom(gameRoom, state)
 
-	ws.RoomLock.Lock()
-	ws.RoomMap[gameRoom.ID] = gameRoom
-	ws.RoomLock.Unlock()
+	game.AddRoom(gameRoom)
+	ws.BindRoom(gameRoom)
 
 	game.StartRoomLoop(gameRoom)
 
-	res.OkWithMsg("游戏已开始", c)
+	// 对局归档后房间回到等待状态，可以开始下一局
+	go func() {
+		<-gameRoom.Quit
+		resetLobbyStatus(room.Code, ws.GameEnded)
+	}()
+	return nil
+}
+
+// resetLobbyStatus 把大厅房间恢复为等待中并以 event 通知成员，房间可能已经解散
+func resetLobbyStatus(code string, event string) {
+	room, err := redis_service.SetPublicRoomStatus(code, ctype.Waiting)
+	if err != nil {
+		global.Log.Warnf("房间 %s 恢复等待失败: %v", code, err)
+		return
+	}
+	ws.PublishLobbyEvent(room, event, room.OwnerID)
+}
+
+// leaveCurrentRoom 离开用户当前所在的房间并通知剩余成员
+func leaveCurrentRoom(userID uint) {
+	code, err := redis_service.GetUserRoomCode(userID)
+	if err != nil {
+		return
+	}
+	if room, err := redis_service.RemovePlayerFromRoom(code, userID); err == nil {
+		ws.PublishLobbyEvent(room, ws.MemberLeft, userID)
+	}
 }
 
 // parseTokenFromHeader 解析 Authorization Bearer Token
@@ -3,17 +3,24 @@ package api
 import (
 	"github.com/gin-gonic/gin"
 	"plane_war/internal/model"
+	"plane_war/internal/model/res"
+	"plane_war/internal/service/match"
 	"plane_war/internal/utils/jwts"
 )
 
 func MatchHandler(c *gin.Context) {
 	//获取用户信息
-	_cliams,_ :=c.Get("cliams")
+	_cliams, _ := c.Get("claims")
 	cliam := _cliams.(*jwts.CustomClaims)
 
 	player := &model.Player{
 		UserID: cliam.UserID,
-		Name: cliam.Nickname,
+		Name:   cliam.Nickname,
 	}
-	room :=
+	room := match.MatchQueueInstance.AddPlayer(player)
+	if room == nil {
+		res.OkWithMsg("正在匹配中", c)
+		return
+	}
+	res.OkWithData(map[string]any{"room_id": room.ID}, c)
 }
@@ -21,7 +21,11 @@ var Upgrader = websocket.Upgrader{
 func WsHandler(c *gin.Context) {
 	// 从JWT中解析玩家信息
 	_cliams, _ := c.Get("claims")
-	claims := _cliams.(*jwts.CustomClaims)
+	claims, ok := _cliams.(*jwts.CustomClaims)
+	if !ok {
+		c.String(http.StatusUnauthorized, "未登录")
+		return
+	}
 
 	conn, err := Upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -30,10 +34,11 @@ func WsHandler(c *gin.Context) {
 	}
 	//创建player
 	player := model.Player{
-		ID:   strconv.Itoa(int(claims.UserID)),
-		Name: claims.Nickname,
-		Conn: conn,
-		HP:   100,
+		ID:     strconv.Itoa(int(claims.UserID)),
+		UserID: claims.UserID,
+		Name:   claims.Nickname,
+		Conn:   conn,
+		HP:     100,
 	}
 	//创建client 并注册到hub
 	client := ws.NewClientWithPlayer(&player)
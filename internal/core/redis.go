@@ -18,7 +18,6 @@ func InitRedis(addr string, pwd string, db int) (client *redis.Client) {
 	_, err := rdb.Ping().Result()
 	if err != nil {
 		panic(err)
-		return
 	}
 	return rdb
 }
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"plane_war/internal/global"
 	"plane_war/internal/model/res"
 	"plane_war/internal/service/redis_service"
@@ -28,31 +29,59 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		tokenString := parts[1]
 
-		// 3. 解析 JWT
-		claims, err := jwts.ParseToken(tokenString)
+		// 3. 解析 JWT 并检查 Redis 白名单
+		claims, err := checkAccessToken(parts[1])
 		if err != nil {
-			global.Log.Error(err.Error())
-			res.FailWithMsg("Token 无效或已过期", c)
+			res.FailWithMsg(err.Error(), c)
 			c.Abort()
 			return
 		}
-		// 4. 检查 Redis 中 token 是否存在
-		userID, ok := redis_service.GetUserIDByAccessToken(tokenString)
-		if !ok {
-			res.FailWithMsg("Token 已失效，请重新登录", c)
+		// 4. 注入用户信息到 Context
+		c.Set("claims", claims)
+
+		c.Next()
+	}
+}
+
+// WsAuthMiddleware WebSocket 鉴权中间件，浏览器建立 WebSocket 时不能设置 header，token 通过查询参数 token 传入，
+// 可以带 Bearer 前缀
+func WsAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimSpace(c.Query("token"))
+		if parts := strings.Fields(token); len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+			token = parts[1]
+		}
+		if token == "" {
+			res.FailWithMsg("未携带 token", c)
 			c.Abort()
 			return
 		}
-		if userID != claims.UserID {
-			res.FailWithMsg("Token 与用户信息不匹配", c)
+		claims, err := checkAccessToken(token)
+		if err != nil {
+			res.FailWithMsg(err.Error(), c)
 			c.Abort()
 			return
 		}
-		// 5. 注入用户信息到 Context
 		c.Set("claims", claims)
 
 		c.Next()
 	}
 }
+
+// checkAccessToken 解析 access token，并确认它仍在 Redis 中且属于同一用户
+func checkAccessToken(tokenString string) (*jwts.CustomClaims, error) {
+	claims, err := jwts.ParseToken(tokenString)
+	if err != nil {
+		global.Log.Error(err.Error())
+		return nil, fmt.Errorf("Token 无效或已过期")
+	}
+	userID, ok := redis_service.GetUserIDByAccessToken(tokenString)
+	if !ok {
+		return nil, fmt.Errorf("Token 已失效，请重新登录")
+	}
+	if userID != claims.UserID {
+		return nil, fmt.Errorf("Token 与用户信息不匹配")
+	}
+	return claims, nil
+}
@@ -0,0 +1,9 @@
+package model
+
+// AbilityState 玩家技能的冷却状态，供前端 HUD 展示
+type AbilityState struct {
+	Name     string `json:"name"`
+	ReadyAt  int64  `json:"ready_at"` //冷却结束时间（毫秒时间戳）
+	Cooldown int64  `json:"cooldown"` //剩余冷却（毫秒），0 表示可用
+	Pending  bool   `json:"-"`        //客户端已请求释放，等待游戏循环处理
+}
@@ -0,0 +1,9 @@
+package ctype
+
+// GameMode 对局模式
+type GameMode string
+
+const (
+	ModePvP  GameMode = "pvp"  // 双人对战
+	ModeCoop GameMode = "coop" // 多人合作打敌机波次
+)
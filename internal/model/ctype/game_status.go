@@ -0,0 +1,47 @@
+package ctype
+
+import "encoding/json"
+
+// GameStatus 对局房间的状态，按 created → countdown → running ⇄ paused → finished → archived 流转
+type GameStatus int8
+
+const (
+	GameCreated   GameStatus = iota + 1 // 已创建，尚未开始循环
+	GameCountdown                       // 开局倒计时
+	GameRunning                         // 对局进行中
+	GamePaused                          // 全员同意后暂停
+	GameFinished                        // 已分出结果，等待保存
+	GameArchived                        // 结果已保存，房间已移除
+)
+
+var gameStatusNames = map[GameStatus]string{
+	GameCreated:   "created",
+	GameCountdown: "countdown",
+	GameRunning:   "running",
+	GamePaused:    "paused",
+	GameFinished:  "finished",
+	GameArchived:  "archived",
+}
+
+func (s GameStatus) String() string {
+	return gameStatusNames[s]
+}
+
+func (s GameStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *GameStatus) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	for k, v := range gameStatusNames {
+		if v == name {
+			*s = k
+			return nil
+		}
+	}
+	*s = 0
+	return nil
+}
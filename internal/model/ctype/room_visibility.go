@@ -0,0 +1,19 @@
+package ctype
+
+// RoomVisibility 大厅房间的可见性
+type RoomVisibility string
+
+const (
+	VisibilityPublic   RoomVisibility = "public"   // 出现在房间列表中，任何人可加入
+	VisibilityUnlisted RoomVisibility = "unlisted" // 不出现在房间列表中，凭房间码或邀请加入
+	VisibilityPassword RoomVisibility = "password" // 出现在房间列表中，加入需要密码或邀请
+)
+
+// Valid 是否为已知的可见性
+func (v RoomVisibility) Valid() bool {
+	switch v {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPassword:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Enemy 合作模式中由服务端控制的敌机
+type Enemy struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"` //敌机类型
+	X        float64   `json:"x"`
+	Y        float64   `json:"y"`
+	Width    float64   `json:"width"`
+	Height   float64   `json:"height"`
+	HP       int       `json:"hp"`
+	MaxHP    int       `json:"max_hp"`
+	Boss     bool      `json:"boss"`
+	Phase    int       `json:"phase"` //Boss 当前阶段，从 0 开始
+	Wave     int       `json:"-"`     //所属波次下标
+	Spawn    int       `json:"-"`     //在波次中的生成项下标，用于查找路径
+	Age      int       `json:"-"`     //已存活的 tick 数
+	NextFire time.Time `json:"-"`     //下一次开火时间
+}
+
+// PvEState 合作模式的进度
+type PvEState struct {
+	Script    string    `json:"script"` //波次脚本
+	Wave      int       `json:"wave"`   //当前波次，从 1 开始
+	Waves     int       `json:"waves"`  //总波次数
+	Score     int       `json:"score"`  //全队共享得分
+	WaveStart time.Time `json:"-"`      //当前波次开始时间
+	Spawned   int       `json:"-"`      //当前波次已生成的敌机数
+}
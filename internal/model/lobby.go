@@ -6,11 +6,36 @@ import (
 )
 
 type PublicRoom struct {
-	ID       string           `json:"id"`
-	Code     string           `json:"code"`     // 房间码
-	OwnerID  uint             `json:"owner_id"` // 房间创建者用户ID
-	Players  []*Player        `json:"players"`  // 房间内玩家
-	Status   ctype.RoomStatus `json:"status"`   // 状态：等待中/游戏中
-	Created  time.Time        `json:"created"`  // 创建时间
-	Capacity int              `json:"capacity"` // 房间最大玩家数
+	ID      string           `json:"id"`
+	Code    string           `json:"code"`           // 房间码
+	OwnerID uint             `json:"owner_id"`       // 房间创建者用户ID
+	Players []*Player        `json:"players"`        // 房间内玩家
+	Status  ctype.RoomStatus `json:"status"`         // 状态：等待中/游戏中
+	Game    ctype.GameStatus `json:"game,omitempty"` // 开局后对局房间的状态，仅查询时填充
+	Created time.Time        `json:"created"`        // 创建时间
+	// RoomSettings 房间设置，JSON 中平铺在房间字段里
+	RoomSettings
+	// Visibility 可见性，unlisted 房间不出现在房间列表中；密码哈希单独保存，不随房间返回
+	Visibility ctype.RoomVisibility `json:"visibility"`
+	// AutoStart 全员准备后自动倒计时开始，AutoStartAt 为预定的开始时间（毫秒时间戳），0 表示未在倒计时
+	AutoStart   bool  `json:"auto_start"`
+	AutoStartAt int64 `json:"auto_start_at,omitempty"`
+	// Locked 房主锁定后不再接受新玩家加入；Banned 被房主封禁的用户，房间存在期间不能再加入
+	Locked bool   `json:"locked"`
+	Banned []uint `json:"banned,omitempty"`
+	// StartedAt 占住开局的时间（毫秒时间戳），对局房间在其后才创建，等待中为 0
+	StartedAt int64 `json:"started_at,omitempty"`
+}
+
+// RoomSettings 房主在开局前可以修改的房间设置，开局时带入对局房间
+type RoomSettings struct {
+	Name         string         `json:"name"`          // 房间名，房间列表可按名称搜索
+	Mode         ctype.GameMode `json:"mode"`          // 游戏模式
+	Arena        string         `json:"arena"`         // 场地，为空使用默认场地
+	Capacity     int            `json:"capacity"`      // 房间最大玩家数
+	Rounds       int            `json:"rounds"`        // 对战模式的局数，先赢下过半局数者获胜
+	FriendlyFire bool           `json:"friendly_fire"` // 合作模式中队友的子弹能否互相伤害
+	TimeLimit    int            `json:"time_limit"`    // 每局时间上限（秒），0 表示不限
+	TickRate     int            `json:"tick_rate"`     // 模拟频率（Hz），0 表示使用配置的默认值
+	SendRate     int            `json:"send_rate"`     // 快照发送频率（Hz），0 表示使用配置的默认值
 }
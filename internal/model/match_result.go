@@ -0,0 +1,34 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"plane_war/internal/model/ctype"
+	"time"
+)
+
+// MatchResult 对局结果，房间结束后写入
+type MatchResult struct {
+	gorm.Model
+	RoomID    string         `gorm:"size:64;index" json:"room_id"`
+	Mode      ctype.GameMode `gorm:"size:16" json:"mode"`
+	Arena     string         `gorm:"size:32" json:"arena"`
+	WinnerID  uint           `json:"winner_id"`             // 对战模式胜者的用户ID，0 表示无胜者
+	Victory   bool           `json:"victory"`               // 合作模式是否通关
+	Score     int            `json:"score"`                 // 合作模式全队得分
+	Reason    string         `gorm:"size:16" json:"reason"` // 提前结束的原因：surrender / draw
+	Ticks     uint64         `json:"ticks"`                 // 对局持续的 tick 数
+	StartedAt time.Time      `json:"started_at"`
+	EndedAt   time.Time      `json:"ended_at"`
+	Players   []MatchPlayer  `json:"players"`
+}
+
+// MatchPlayer 对局中单个玩家的结果
+type MatchPlayer struct {
+	gorm.Model
+	MatchResultID uint   `gorm:"index" json:"match_result_id"`
+	UserID        uint   `json:"user_id"`
+	Name          string `gorm:"size:32" json:"name"`
+	Plane         string `gorm:"size:32" json:"plane"`
+	HP            int    `json:"hp"`        // 结束时的剩余血量
+	Surrender     bool   `json:"surrender"` // 是否投降
+}
@@ -6,13 +6,30 @@ import (
 
 // Player 玩家信息
 type Player struct {
-	ID       string          `json:"id"`
-	UserID   uint            `json:"user_id"`
-	Name     string          `json:"name"`
-	X        int             `json:"x"`        // 玩家位置 X
-	Y        int             `json:"y"`        // 玩家位置 Y
-	HP       int             `json:"hp"`       //玩家血量
-	Position string          `json:"position"` //top or bottom
-	Conn     *websocket.Conn `json:"-"`
-	Ready    bool            `json:"ready"`
+	ID        string          `json:"id"`
+	UserID    uint            `json:"user_id"`
+	Name      string          `json:"name"`
+	X         int             `json:"x"`        // 玩家位置 X
+	Y         int             `json:"y"`        // 玩家位置 Y
+	HP        int             `json:"hp"`       //玩家血量
+	MaxHP     int             `json:"max_hp"`   //血量上限，由机型决定
+	Speed     int             `json:"speed"`    //每 tick 最大移动距离，由机型决定
+	Plane     string          `json:"plane"`    //机型
+	Width     int             `json:"width"`    //碰撞盒宽度
+	Height    int             `json:"height"`   //碰撞盒高度
+	Position  string          `json:"position"` //top or bottom
+	Conn      *websocket.Conn `json:"-"`        //只允许连接的 WritePump 写入，其他地方通过 Send 推送
+	Send      func([]byte)    `json:"-"`        //把消息放入连接的发送队列，由 ws 在创建连接时设置
+	Ready     bool            `json:"ready"`
+	Loadout   string          `json:"loadout"`          //选择的武器
+	Weapon    *WeaponState    `json:"weapon,omitempty"` //武器状态
+	Shield    int             `json:"shield"`           //护盾剩余可吸收伤害
+	Effects   []*Effect       `json:"effects"`          //生效中的道具效果
+	Abilities []*AbilityState `json:"abilities"`        //机型技能及冷却
+	Ping      int             `json:"ping"`             //平滑后的往返延迟（毫秒）
+	Jitter    int             `json:"jitter"`           //延迟抖动（毫秒）
+	PauseLeft int64           `json:"pause_left"`       //剩余可用的暂停时长（毫秒）
+	Surrender bool            `json:"surrender"`        //是否已投降
+	TargetX   int             `json:"-"`                //客户端请求移动到的位置，由游戏循环按速度逼近
+	TargetY   int             `json:"-"`
 }
@@ -0,0 +1,17 @@
+package model
+
+// PowerUp 场地上等待拾取的道具
+type PowerUp struct {
+	ID        string  `json:"id"`
+	Kind      string  `json:"kind"` //道具类型：shield/heal/triple_shot/speed_boost
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Size      float64 `json:"size"`
+	ExpiresAt int64   `json:"expires_at"` //未被拾取时的消失时间（毫秒时间戳）
+}
+
+// Effect 玩家身上生效中的道具效果
+type Effect struct {
+	Kind      string `json:"kind"`
+	ExpiresAt int64  `json:"expires_at"` //失效时间（毫秒时间戳）
+}
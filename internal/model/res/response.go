@@ -17,8 +17,9 @@ type Response struct {
 	Msg  string `json:"msg"`
 }
 type ListResponse[T any] struct {
-	Count int64 `json:"count"`
-	List  T     `json:"list"`
+	Count int64  `json:"count"`
+	List  T      `json:"list"`
+	Next  string `json:"next,omitempty"` // 游标分页时下一页的游标，为空表示没有更多
 }
 
 func Result(code int, data any, msg string, c *gin.Context) {
@@ -35,9 +36,13 @@ func OkWithData(data any, c *gin.Context) {
 	Result(Success, data, "成功", c)
 }
 func OkWithList(lst any, count int64, c *gin.Context) {
+	OkWithPage(lst, count, "", c)
+}
+func OkWithPage(lst any, count int64, next string, c *gin.Context) {
 	OkWithData(ListResponse[any]{
 		List:  lst,
 		Count: count,
+		Next:  next,
 	}, c)
 }
 func OkWithMsg(msg string, c *gin.Context) {
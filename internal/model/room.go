@@ -2,27 +2,85 @@ package model
 
 import (
 	"gorm.io/gorm"
+	"math/rand"
+	"plane_war/internal/model/ctype"
 	"sync"
 	"time"
 )
 
 // Room 房间信息
 type Room struct {
-	ID      string       `json:"id"` //房间id
-	Players []*Player    //房间内玩家
-	Bullets []*Bullet    //房间内的子弹
-	Lock    sync.Mutex   //房间锁，防止并发操作
-	Ticker  *time.Ticker //用于房间循环
-	Quit    chan bool    //用于房间循环
+	ID           string           `json:"id"` //房间id
+	Mode         ctype.GameMode   //游戏模式
+	Arena        string           //场地名称
+	Tick         uint64           //已执行的 tick 数
+	TickRate     int              //模拟频率（Hz）
+	SendRate     int              //快照发送频率（Hz）
+	Status       ctype.GameStatus //对局状态
+	Vote         *Vote            //进行中的暂停/和局投票
+	PausedBy     string           //发起当前暂停的玩家，暂停时长从他的额度中扣除
+	PausedAt     time.Time        //本次暂停开始时间
+	PauseEnd     time.Time        //本次暂停最晚结束时间
+	EndReason    string           //提前结束的原因：surrender / draw / timeout
+	Rounds       int              //对战模式的总局数，先赢下过半局数者获胜
+	Round        int              //当前是第几局
+	RoundWins    map[string]int   //每名玩家赢下的局数
+	FriendlyFire bool             //合作模式中队友的子弹能否互相伤害
+	TimeLimit    time.Duration    //每局时间上限，0 表示不限
+	RoundEnd     time.Time        //本局的截止时间，暂停时顺延
+	Winner       *Player          //对战模式的胜者
+	Victory      bool             //合作模式是否通关
+	StartAt      time.Time        //倒计时结束、对局开始的时间
+	Countdown    int              //最近一次广播的倒计时秒数
+	StartedAt    time.Time        //实际开始对局的时间
+	EndedAt      time.Time        //分出结果的时间
+	Players      []*Player        //房间内玩家
+	Bullets      []*Bullet        //房间内的子弹
+	PowerUps     []*PowerUp       //场地上的道具
+	Obstacles    []*Obstacle      //场地障碍物
+	Enemies      []*Enemy         //合作模式的敌机
+	PvE          *PvEState        //合作模式进度
+	Rand         *rand.Rand       //房间内的随机数，道具刷新等都使用它
+	NextDrop     time.Time        //下一次刷新道具的时间
+	History      []*TickPositions //最近若干 tick 的玩家位置，用于延迟补偿
+	Lock         sync.Mutex       //房间锁，防止并发操作
+	Ticker       *time.Ticker     //用于房间循环
+	Quit         chan bool        //房间归档后关闭
 }
 
 // Bullet 子弹信息
 type Bullet struct {
 	gorm.Model
-	ID     string `json:"id"`
-	X      int    `json:"x"`
-	Y      int    `json:"y"`
-	Owner  string `json:"owner"` //玩家ID
-	Speed  int    `json:"speed"`
-	Damage int    `json:"damage"`
+	ID     string   `json:"id"`
+	Weapon string   `json:"weapon"` //所属武器，前端据此选择贴图
+	X      float64  `json:"x"`
+	Y      float64  `json:"y"`
+	VX     float64  `json:"vx"` //每 tick 水平位移
+	VY     float64  `json:"vy"` //每 tick 垂直位移
+	Width  float64  `json:"width"`
+	Height float64  `json:"height"`
+	Shape  string   `json:"shape,omitempty"` //碰撞形状，默认矩形
+	Owner  string   `json:"owner"`           //玩家ID
+	Damage int      `json:"damage"`
+	Pierce int      `json:"-"` //剩余可穿透目标数
+	Homing float64  `json:"-"` //每 tick 最大转向角度（度）
+	Life   int      `json:"-"` //剩余存活 tick 数，0 表示不限
+	Rewind uint64   `json:"-"` //命中判定回溯的 tick 数，由射手开火时的延迟决定
+	HitIDs []string `json:"-"` //已命中的玩家，穿透子弹不会重复命中
+}
+
+// TickPositions 某个 tick 结束时各存活玩家的位置（碰撞盒左上角）
+type TickPositions struct {
+	Tick      uint64
+	Positions map[string][2]int
+}
+
+// Obstacle 场地中的障碍物，阻挡飞机和子弹
+type Obstacle struct {
+	ID     string  `json:"id"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Moving bool    `json:"moving"` //是否为移动障碍物
 }
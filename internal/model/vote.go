@@ -0,0 +1,9 @@
+package model
+
+// Vote 对局内的投票，房间内全部玩家同意后生效
+type Vote struct {
+	Kind      string   `json:"kind"`       //pause / draw
+	From      string   `json:"from"`       //发起投票的玩家
+	Voters    []string `json:"voters"`     //已同意的玩家
+	ExpiresAt int64    `json:"expires_at"` //投票截止时间（毫秒时间戳）
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// WeaponState 玩家武器状态，完全由服务端游戏循环维护
+type WeaponState struct {
+	Name          string    `json:"name"`          // 武器名称
+	Ammo          int       `json:"ammo"`          // 弹匣剩余子弹
+	MagazineSize  int       `json:"magazine_size"` // 弹匣容量
+	Reloading     bool      `json:"reloading"`     // 是否正在换弹
+	PendingShots  int       `json:"-"`             // 上个 tick 以来收到的开火请求数
+	RejectedShots int       `json:"-"`             // 被拒绝的开火请求总数，用于反作弊统计
+	LastFireAt    time.Time `json:"-"`             // 上次开火时间
+	ReloadDoneAt  time.Time `json:"-"`             // 换弹完成时间
+	RenderTick    uint64    `json:"-"`             // 最近一次开火时客户端正在渲染的 tick
+}
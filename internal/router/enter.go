@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"plane_war/internal/api"
 	"plane_war/internal/global"
+	"plane_war/internal/middleware"
 )
 
 type RouterGroup struct {
@@ -23,7 +24,8 @@ func InitRouter() *gin.Engine {
 	routerGroupApp := RouterGroup{apiRouterGroup}
 	routerGroupApp.AuthRouter()
 	routerGroupApp.LobbyRouter()
+	routerGroupApp.GameRouter()
 	// WebSocket 路由
-	r.GET("/ws", api.WsHandler) // WebSocket 路由
+	r.GET("/ws", middleware.WsAuthMiddleware(), api.WsHandler) // WebSocket 路由，token 通过查询参数传入
 	return r
 }
@@ -0,0 +1,11 @@
+package router
+
+import (
+	"plane_war/internal/api"
+	"plane_war/internal/middleware"
+)
+
+func (r RouterGroup) GameRouter() {
+	r.GET("/game/rooms", middleware.AuthMiddleware(), api.GetGameRooms)
+	r.GET("/game/room", middleware.AuthMiddleware(), api.GetGameRoom)
+}
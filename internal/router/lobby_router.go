@@ -11,4 +11,11 @@ func (r RouterGroup) LobbyRouter() {
 	r.POST("/lobby/join_room", middleware.AuthMiddleware(), api.JoinPublicRoom)
 	r.GET("/lobby/start_game", middleware.AuthMiddleware(), api.StartGame)
 	r.GET("/lobby/dismiss_room", middleware.AuthMiddleware(), api.DismissPublicRoom)
+	r.POST("/lobby/invite", middleware.AuthMiddleware(), api.CreateInvite)
+	r.POST("/lobby/leave_room", middleware.AuthMiddleware(), api.LeavePublicRoom)
+	r.POST("/lobby/toggle_ready", middleware.AuthMiddleware(), api.ToggleReady)
+	r.POST("/lobby/update_room", middleware.AuthMiddleware(), api.UpdatePublicRoom)
+	r.POST("/lobby/kick", middleware.AuthMiddleware(), api.KickMember)
+	r.POST("/lobby/lock_room", middleware.AuthMiddleware(), api.LockPublicRoom)
+	r.POST("/lobby/transfer_owner", middleware.AuthMiddleware(), api.TransferOwner)
 }
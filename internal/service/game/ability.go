@@ -0,0 +1,146 @@
+package game
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"math"
+	"plane_war/internal/model"
+	"time"
+)
+
+// 技能名称
+const (
+	AbilityDash   = "dash"   // 短距离无敌冲刺
+	AbilityBomb   = "bomb"   // 清除半径内的敌方子弹
+	AbilityShield = "shield" // 短时间免疫全部伤害
+)
+
+// EffectInvulnerable 无敌效果，冲刺与护盾技能期间生效
+const EffectInvulnerable = "invulnerable"
+
+// Ability 技能参数，由 internal/etc/abilities.yaml 定义
+type Ability struct {
+	Name     string        `mapstructure:"name"`
+	Cooldown time.Duration `mapstructure:"cooldown"` // 冷却时间
+	Duration time.Duration `mapstructure:"duration"` // 无敌持续时间（冲刺、护盾）
+	Distance float64       `mapstructure:"distance"` // 冲刺距离
+	Radius   float64       `mapstructure:"radius"`   // 炸弹清弹半径
+}
+
+// Abilities 技能列表
+var Abilities = map[string]Ability{}
+
+// LoadAbilities 从配置文件加载技能定义
+func LoadAbilities(file string) error {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("读取技能配置失败: %v", err)
+	}
+	var list []Ability
+	if err := v.UnmarshalKey("abilities", &list); err != nil {
+		return fmt.Errorf("解析技能配置失败: %v", err)
+	}
+
+	abilities := make(map[string]Ability, len(list))
+	for _, a := range list {
+		switch a.Name {
+		case AbilityDash, AbilityBomb, AbilityShield:
+		default:
+			return fmt.Errorf("未知的技能: %s", a.Name)
+		}
+		if a.Cooldown <= 0 {
+			return fmt.Errorf("技能 %s 的 cooldown 必须大于 0", a.Name)
+		}
+		abilities[a.Name] = a
+	}
+	Abilities = abilities
+	return nil
+}
+
+// newAbilityStates 为机型的技能生成冷却状态，开局即可使用
+func newAbilityStates(names []string) []*model.AbilityState {
+	states := make([]*model.AbilityState, 0, len(names))
+	for _, name := range names {
+		states = append(states, &model.AbilityState{Name: name})
+	}
+	return states
+}
+
+// RequestAbility 记录玩家的技能释放请求，冷却由游戏循环校验，调用方需持有 room.Lock
+func RequestAbility(p *model.Player, name string) error {
+	for _, a := range p.Abilities {
+		if a.Name == name {
+			a.Pending = true
+			return nil
+		}
+	}
+	return fmt.Errorf("当前机型没有技能 %s", name)
+}
+
+// useAbilities 释放冷却完毕的技能，并刷新剩余冷却时间
+func useAbilities(room *model.Room, now time.Time) {
+	nowMs := now.UnixMilli()
+	for _, p := range room.Players {
+		for _, st := range p.Abilities {
+			if st.Pending {
+				st.Pending = false
+				if p.HP > 0 && nowMs >= st.ReadyAt {
+					def := Abilities[st.Name]
+					castAbility(room, p, def, now)
+					st.ReadyAt = now.Add(def.Cooldown).UnixMilli()
+				}
+			}
+			st.Cooldown = max(st.ReadyAt-nowMs, 0)
+		}
+	}
+}
+
+func castAbility(room *model.Room, p *model.Player, def Ability, now time.Time) {
+	switch def.Name {
+	case AbilityDash:
+		dash(room, p, def.Distance)
+		addEffect(p, EffectInvulnerable, now.Add(def.Duration))
+	case AbilityBomb:
+		clearBullets(room, p, def.Radius)
+	case AbilityShield:
+		addEffect(p, EffectInvulnerable, now.Add(def.Duration))
+	}
+}
+
+// dash 沿当前移动方向瞬移，静止时向机头方向冲刺，遇到障碍物时停在障碍物前
+func dash(room *model.Room, p *model.Player, distance float64) {
+	dx := float64(p.TargetX - p.X)
+	dy := float64(p.TargetY - p.Y)
+	dist := math.Hypot(dx, dy)
+	if dist == 0 {
+		dx, dy, dist = 0, -1, 1
+		if p.Position == "top" {
+			dy = 1
+		}
+	}
+	arena := ArenaOf(room)
+	for d := distance; d > 0; d -= 10 {
+		nx := clampInt(p.X+int(dx/dist*d), 0, arena.Width-p.Width)
+		ny := clampInt(p.Y+int(dy/dist*d), 0, arena.Height-p.Height)
+		if !planeBlocked(room, p, nx, ny) {
+			p.X, p.Y = nx, ny
+			break
+		}
+	}
+	p.TargetX, p.TargetY = p.X, p.Y
+}
+
+// clearBullets 清除以玩家为中心、半径内的所有敌方子弹
+func clearBullets(room *model.Room, p *model.Player, radius float64) {
+	cx, cy := planeCenter(p)
+	remain := room.Bullets[:0]
+	for _, b := range room.Bullets {
+		bx, by := b.X+b.Width/2, b.Y+b.Height/2
+		if b.Owner != p.ID && math.Hypot(bx-cx, by-cy) <= radius {
+			continue
+		}
+		remain = append(remain, b)
+	}
+	room.Bullets = remain
+}
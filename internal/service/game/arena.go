@@ -0,0 +1,150 @@
+package game
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"math"
+	"path/filepath"
+	"plane_war/internal/model"
+	"strings"
+	"time"
+)
+
+// SpawnPoint 出生点，坐标为飞机碰撞盒的中心
+type SpawnPoint struct {
+	X int `mapstructure:"x" json:"x"`
+	Y int `mapstructure:"y" json:"y"`
+}
+
+// ObstacleDef 障碍物定义，dx/dy 非零时在原点与偏移点之间往返移动
+type ObstacleDef struct {
+	X      float64       `mapstructure:"x" json:"x"`
+	Y      float64       `mapstructure:"y" json:"y"`
+	Width  float64       `mapstructure:"width" json:"width"`
+	Height float64       `mapstructure:"height" json:"height"`
+	DX     float64       `mapstructure:"dx" json:"dx"`         // 水平移动距离
+	DY     float64       `mapstructure:"dy" json:"dy"`         // 垂直移动距离
+	Period time.Duration `mapstructure:"period" json:"period"` // 往返一次的时间
+}
+
+// Arena 场地定义，由 internal/etc/arenas/ 下的文件定义，文件名即场地名
+type Arena struct {
+	Name        string  `json:"name"`
+	Width       int     `mapstructure:"width" json:"width"`
+	Height      int     `mapstructure:"height" json:"height"`
+	Background  string  `mapstructure:"background" json:"background"`     // 背景贴图
+	ScrollSpeed float64 `mapstructure:"scroll_speed" json:"scroll_speed"` // 背景滚动速度（像素/秒），仅影响客户端表现
+	Spawns      struct {
+		Top    []SpawnPoint `mapstructure:"top" json:"top"`
+		Bottom []SpawnPoint `mapstructure:"bottom" json:"bottom"`
+	} `mapstructure:"spawns" json:"spawns"`
+	Obstacles []ObstacleDef `mapstructure:"obstacles" json:"obstacles"`
+}
+
+var (
+	// Arenas 场地列表
+	Arenas = map[string]*Arena{}
+	// DefaultArena 未指定或指定了不存在的场地时使用
+	DefaultArena = "default"
+)
+
+// LoadArenas 加载 dir 目录下的全部场地
+func LoadArenas(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return fmt.Errorf("读取场地目录失败: %v", err)
+	}
+	arenas := make(map[string]*Arena, len(files))
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("读取场地 %s 失败: %v", file, err)
+		}
+		var a Arena
+		if err := v.Unmarshal(&a); err != nil {
+			return fmt.Errorf("解析场地 %s 失败: %v", file, err)
+		}
+		a.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if a.Width <= 0 || a.Height <= 0 {
+			return fmt.Errorf("场地 %s 的 width/height 必须大于 0", a.Name)
+		}
+		if len(a.Spawns.Top) == 0 || len(a.Spawns.Bottom) == 0 {
+			return fmt.Errorf("场地 %s 的上下两侧都至少需要一个出生点", a.Name)
+		}
+		arenas[a.Name] = &a
+	}
+	if _, ok := arenas[DefaultArena]; !ok {
+		return fmt.Errorf("缺少默认场地 %s.yaml", DefaultArena)
+	}
+	Arenas = arenas
+	return nil
+}
+
+// ArenaExists 判断场地是否存在
+func ArenaExists(name string) bool {
+	_, ok := Arenas[name]
+	return ok
+}
+
+// ArenaOf 房间使用的场地
+func ArenaOf(room *model.Room) *Arena {
+	if a, ok := Arenas[room.Arena]; ok {
+		return a
+	}
+	return Arenas[DefaultArena]
+}
+
+// initObstacles 按场地定义生成障碍物
+func initObstacles(room *model.Room) {
+	arena := ArenaOf(room)
+	room.Arena = arena.Name
+	room.Obstacles = make([]*model.Obstacle, 0, len(arena.Obstacles))
+	for i, def := range arena.Obstacles {
+		room.Obstacles = append(room.Obstacles, &model.Obstacle{
+			ID:     fmt.Sprintf("obstacle-%d", i),
+			X:      def.X,
+			Y:      def.Y,
+			Width:  def.Width,
+			Height: def.Height,
+			Moving: def.Period > 0 && (def.DX != 0 || def.DY != 0),
+		})
+	}
+}
+
+// moveObstacles 移动障碍物在原点与偏移点之间做三角波往返
+func moveObstacles(room *model.Room) {
+	arena := ArenaOf(room)
+	elapsed := time.Duration(room.Tick) * tickInterval(room)
+	for i, o := range room.Obstacles {
+		if !o.Moving {
+			continue
+		}
+		def := arena.Obstacles[i]
+		phase := math.Mod(float64(elapsed), float64(def.Period)) / float64(def.Period)
+		f := 1 - math.Abs(2*phase-1) // 0 -> 1 -> 0
+		o.X = def.X + def.DX*f
+		o.Y = def.Y + def.DY*f
+	}
+}
+
+// hitObstacle 判断矩形是否与任一障碍物相交
+func hitObstacle(room *model.Room, x, y, w, h float64) bool {
+	for _, o := range room.Obstacles {
+		if x < o.X+o.Width && x+w > o.X && y < o.Y+o.Height && y+h > o.Y {
+			return true
+		}
+	}
+	return false
+}
+
+// planeBlocked 判断飞机移动到 (x, y) 是否会撞上障碍物
+func planeBlocked(room *model.Room, p *model.Player, x, y int) bool {
+	return hitObstacle(room, float64(x), float64(y), float64(p.Width), float64(p.Height))
+}
+
+// spawnPoint 第 i 个同侧玩家的出生位置（碰撞盒左上角），出生点不足时循环使用
+func spawnPoint(points []SpawnPoint, i int, p *model.Player) (int, int) {
+	sp := points[i%len(points)]
+	return sp.X - p.Width/2, sp.Y - p.Height/2
+}
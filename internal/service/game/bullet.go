@@ -0,0 +1,155 @@
+package game
+
+import (
+	"math"
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+)
+
+// updateBullets 移动子弹并处理命中，返回仍然存活的子弹
+func updateBullets(room *model.Room) []*model.Bullet {
+	arena := ArenaOf(room)
+	w, h := float64(arena.Width), float64(arena.Height)
+	scale := tickScale(room)
+	alive := make([]*model.Bullet, 0, len(room.Bullets))
+	// 每 tick 建一次网格，子弹只和附近格子里的目标做精确判定
+	grid := buildTargetGrid(room)
+	for _, bullet := range room.Bullets {
+		if bullet.Homing > 0 {
+			if tx, ty, ok := homingTarget(room, bullet); ok {
+				steerBullet(bullet, tx, ty, scale)
+			}
+		}
+		bullet.X += bullet.VX * scale
+		bullet.Y += bullet.VY * scale
+		// 飞出场地或撞上障碍物即销毁
+		if bullet.X+bullet.Width < 0 || bullet.X > w || bullet.Y+bullet.Height < 0 || bullet.Y > h ||
+			hitObstacle(room, bullet.X, bullet.Y, bullet.Width, bullet.Height) {
+			continue
+		}
+
+		if hitTargets(room, grid, bullet) {
+			continue
+		}
+		if bullet.Life > 0 {
+			bullet.Life--
+			if bullet.Life == 0 {
+				continue
+			}
+		}
+		alive = append(alive, bullet)
+	}
+	removeDeadEnemies(room)
+	return alive
+}
+
+// hitTargets 结算子弹命中，子弹需要被销毁时返回 true
+func hitTargets(room *model.Room, grid *spatialGrid, b *model.Bullet) bool {
+	col := bulletCollider(b)
+	destroyed := false
+	grid.each(col, func(t *target) bool {
+		if !canHit(room, b, t) || !collide(col, targetCollider(room, b, t)) {
+			return true
+		}
+		if t.player != nil {
+			applyDamage(t.player, b.Damage)
+		} else {
+			t.enemy.HP -= b.Damage
+			if t.enemy.HP <= 0 {
+				room.PvE.Score += WaveScripts[room.PvE.Script].Enemies[t.enemy.Type].Score
+			}
+		}
+		if b.Pierce <= 0 {
+			destroyed = true
+			return false
+		}
+		b.Pierce--
+		b.HitIDs = append(b.HitIDs, targetID(t))
+		return true
+	})
+	return destroyed
+}
+
+// canHit 合作模式下玩家子弹只打敌机、敌机子弹只打玩家，对战模式不打自己
+func canHit(room *model.Room, b *model.Bullet, t *target) bool {
+	if alreadyHit(b, targetID(t)) {
+		return false
+	}
+	if t.enemy != nil {
+		return b.Owner != enemyOwner && t.enemy.HP > 0
+	}
+	if room.Mode == ctype.ModeCoop && b.Owner != enemyOwner && !room.FriendlyFire {
+		return false
+	}
+	return t.player.ID != b.Owner && t.player.HP > 0
+}
+
+// targetCollider 子弹判定使用的目标形状，玩家子弹按射手延迟回溯敌方位置
+func targetCollider(room *model.Room, b *model.Bullet, t *target) collider {
+	if t.player == nil || b.Rewind == 0 {
+		return t.col
+	}
+	if x, y, ok := positionAt(room, t.player.ID, room.Tick-b.Rewind); ok {
+		return playerColliderAt(t.player, x, y)
+	}
+	return t.col
+}
+
+func targetID(t *target) string {
+	if t.player != nil {
+		return t.player.ID
+	}
+	return t.enemy.ID
+}
+
+func alreadyHit(b *model.Bullet, playerID string) bool {
+	for _, id := range b.HitIDs {
+		if id == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// homingTarget 追踪子弹的目标：合作模式为最近的敌机，对战模式为最近的敌方玩家
+func homingTarget(room *model.Room, b *model.Bullet) (float64, float64, bool) {
+	best := math.MaxFloat64
+	var tx, ty float64
+	if room.Mode == ctype.ModeCoop {
+		for _, e := range room.Enemies {
+			cx, cy := e.X+e.Width/2, e.Y+e.Height/2
+			if d := (cx-b.X)*(cx-b.X) + (cy-b.Y)*(cy-b.Y); d < best {
+				best, tx, ty = d, cx, cy
+			}
+		}
+		return tx, ty, best < math.MaxFloat64
+	}
+	for _, p := range room.Players {
+		if p.ID == b.Owner || p.HP <= 0 {
+			continue
+		}
+		cx, cy := planeCenter(p)
+		if d := (cx-b.X)*(cx-b.X) + (cy-b.Y)*(cy-b.Y); d < best {
+			best, tx, ty = d, cx, cy
+		}
+	}
+	return tx, ty, best < math.MaxFloat64
+}
+
+// steerBullet 将追踪子弹的速度方向朝目标点旋转，单个基准 tick 转角不超过 Homing
+func steerBullet(b *model.Bullet, tx, ty, scale float64) {
+	speed := math.Hypot(b.VX, b.VY)
+	cur := math.Atan2(b.VY, b.VX)
+	want := math.Atan2(ty-b.Y, tx-b.X)
+
+	diff := math.Remainder(want-cur, 2*math.Pi)
+	limit := b.Homing * scale * math.Pi / 180
+	if diff > limit {
+		diff = limit
+	} else if diff < -limit {
+		diff = -limit
+	}
+	cur += diff
+	b.VX = speed * math.Cos(cur)
+	b.VY = speed * math.Sin(cur)
+}
@@ -0,0 +1,49 @@
+package game
+
+import (
+	"fmt"
+	"plane_war/internal/model"
+	"testing"
+	"time"
+)
+
+// tickBudget 20Hz 下每个 tick 的时间预算
+const tickBudget = 50 * time.Millisecond
+
+// BenchmarkUpdateBullets 8 名玩家、500 颗子弹时一次子弹更新的耗时，额外报告占 tick 预算的比例
+func BenchmarkUpdateBullets(b *testing.B) {
+	room := newTestRoom("bench", 8)
+	arena := ArenaOf(room)
+	for _, p := range room.Players {
+		// 血量足够多，保证整个测试中目标一直存活
+		p.HP = 1 << 30
+	}
+	bullets := make([]model.Bullet, 500)
+	for i := range bullets {
+		owner := room.Players[i%len(room.Players)]
+		bullets[i] = model.Bullet{
+			ID:     fmt.Sprintf("b%d", i),
+			X:      float64(room.Rand.Intn(arena.Width)),
+			Y:      float64(room.Rand.Intn(arena.Height)),
+			VX:     float64(room.Rand.Intn(9) - 4),
+			VY:     float64(room.Rand.Intn(21) - 10),
+			Width:  6,
+			Height: 10,
+			Owner:  owner.ID,
+			Damage: 1,
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		room.Bullets = room.Bullets[:0]
+		for j := range bullets {
+			bullet := bullets[j]
+			room.Bullets = append(room.Bullets, &bullet)
+		}
+		b.StartTimer()
+		room.Bullets = updateBullets(room)
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(tickBudget.Nanoseconds())*100, "%budget")
+}
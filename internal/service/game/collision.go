@@ -0,0 +1,282 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"plane_war/internal/model"
+)
+
+// 碰撞形状
+const (
+	ShapeAABB    = "aabb"    // 轴对齐矩形，默认
+	ShapeCircle  = "circle"  // 圆形，圆心为碰撞盒中心
+	ShapePolygon = "polygon" // 凸多边形
+)
+
+// gridCellSize 宽阶段网格的格子边长
+const gridCellSize = 64
+
+// HitboxPoint 多边形顶点，相对碰撞盒左上角
+type HitboxPoint struct {
+	X float64 `mapstructure:"x" json:"x"`
+	Y float64 `mapstructure:"y" json:"y"`
+}
+
+// Hitbox 碰撞形状定义，未配置时使用整个碰撞盒
+type Hitbox struct {
+	Shape  string        `mapstructure:"shape" json:"shape"`
+	Radius float64       `mapstructure:"radius" json:"radius,omitempty"` // 圆形半径，为 0 时取碰撞盒短边的一半
+	Points []HitboxPoint `mapstructure:"points" json:"points,omitempty"` // 凸多边形顶点，按顺序排列，朝上的机头方向
+}
+
+// validHitbox 校验碰撞形状配置，多边形至少需要三个顶点
+func validHitbox(hb Hitbox) error {
+	switch hb.Shape {
+	case "", ShapeAABB, ShapeCircle:
+		return nil
+	case ShapePolygon:
+		if len(hb.Points) < 3 {
+			return fmt.Errorf("多边形至少需要 3 个顶点")
+		}
+		return nil
+	}
+	return fmt.Errorf("未知的形状 %s", hb.Shape)
+}
+
+type vec struct{ x, y float64 }
+
+// collider 窄阶段使用的具体形状，包围盒同时用于宽阶段
+type collider struct {
+	shape                  string
+	minX, minY, maxX, maxY float64
+	cx, cy, r              float64
+	poly                   []vec
+}
+
+func aabbCollider(x, y, w, h float64) collider {
+	return collider{shape: ShapeAABB, minX: x, minY: y, maxX: x + w, maxY: y + h}
+}
+
+// hitboxCollider 将碰撞形状定义放到 (x, y, w, h) 的碰撞盒上，flip 为 true 时上下翻转（机头朝下）
+func hitboxCollider(hb Hitbox, x, y, w, h float64, flip bool) collider {
+	switch hb.Shape {
+	case ShapeCircle:
+		r := hb.Radius
+		if r <= 0 {
+			r = math.Min(w, h) / 2
+		}
+		cx, cy := x+w/2, y+h/2
+		return collider{shape: ShapeCircle, cx: cx, cy: cy, r: r,
+			minX: cx - r, minY: cy - r, maxX: cx + r, maxY: cy + r}
+	case ShapePolygon:
+		c := collider{shape: ShapePolygon, minX: math.MaxFloat64, minY: math.MaxFloat64,
+			maxX: -math.MaxFloat64, maxY: -math.MaxFloat64}
+		for _, pt := range hb.Points {
+			py := pt.Y
+			if flip {
+				py = h - pt.Y
+			}
+			v := vec{x + pt.X, y + py}
+			c.poly = append(c.poly, v)
+			c.minX, c.minY = math.Min(c.minX, v.x), math.Min(c.minY, v.y)
+			c.maxX, c.maxY = math.Max(c.maxX, v.x), math.Max(c.maxY, v.y)
+		}
+		return c
+	}
+	return aabbCollider(x, y, w, h)
+}
+
+func playerCollider(p *model.Player) collider {
+	return playerColliderAt(p, p.X, p.Y)
+}
+
+// playerColliderAt 玩家位于 (x, y) 时的碰撞形状，延迟补偿回溯时使用
+func playerColliderAt(p *model.Player, x, y int) collider {
+	return hitboxCollider(Planes[p.Plane].Hitbox, float64(x), float64(y),
+		float64(p.Width), float64(p.Height), p.Position == "top")
+}
+
+func enemyCollider(room *model.Room, e *model.Enemy) collider {
+	hb := WaveScripts[room.PvE.Script].Enemies[e.Type].Hitbox
+	return hitboxCollider(hb, e.X, e.Y, e.Width, e.Height, false)
+}
+
+func bulletCollider(b *model.Bullet) collider {
+	return hitboxCollider(Hitbox{Shape: b.Shape}, b.X, b.Y, b.Width, b.Height, false)
+}
+
+// collide 窄阶段：先比较包围盒，再按具体形状判断
+func collide(a, b collider) bool {
+	if a.maxX <= b.minX || b.maxX <= a.minX || a.maxY <= b.minY || b.maxY <= a.minY {
+		return false
+	}
+	if a.shape == ShapeAABB && b.shape == ShapeAABB {
+		return true
+	}
+	if a.shape == ShapeCircle && b.shape == ShapeCircle {
+		dx, dy, r := a.cx-b.cx, a.cy-b.cy, a.r+b.r
+		return dx*dx+dy*dy <= r*r
+	}
+	if a.shape == ShapeCircle && b.shape == ShapeAABB {
+		return circleAABB(a, b)
+	}
+	if a.shape == ShapeAABB && b.shape == ShapeCircle {
+		return circleAABB(b, a)
+	}
+	return sat(a, b)
+}
+
+func circleAABB(c, box collider) bool {
+	nx := math.Max(box.minX, math.Min(c.cx, box.maxX))
+	ny := math.Max(box.minY, math.Min(c.cy, box.maxY))
+	dx, dy := c.cx-nx, c.cy-ny
+	return dx*dx+dy*dy <= c.r*c.r
+}
+
+// sat 分离轴判定，处理至少一方为凸多边形的情况
+func sat(a, b collider) bool {
+	axes := append(edgeNormals(a.vertices()), edgeNormals(b.vertices())...)
+	// 圆形没有边，额外检查圆心到对方最近顶点的方向
+	if a.shape == ShapeCircle {
+		axes = append(axes, closestAxis(a, b.vertices()))
+	}
+	if b.shape == ShapeCircle {
+		axes = append(axes, closestAxis(b, a.vertices()))
+	}
+	for _, axis := range axes {
+		if axis.x == 0 && axis.y == 0 {
+			continue
+		}
+		minA, maxA := a.project(axis)
+		minB, maxB := b.project(axis)
+		if maxA < minB || maxB < minA {
+			return false
+		}
+	}
+	return true
+}
+
+func closestAxis(c collider, pts []vec) vec {
+	best, axis := math.MaxFloat64, vec{}
+	for _, v := range pts {
+		d := vec{v.x - c.cx, v.y - c.cy}
+		if l := d.x*d.x + d.y*d.y; l < best {
+			best, axis = l, d
+		}
+	}
+	return axis
+}
+
+// vertices 多边形与矩形的顶点，圆形没有顶点
+func (c collider) vertices() []vec {
+	switch c.shape {
+	case ShapePolygon:
+		return c.poly
+	case ShapeAABB:
+		return []vec{{c.minX, c.minY}, {c.maxX, c.minY}, {c.maxX, c.maxY}, {c.minX, c.maxY}}
+	}
+	return nil
+}
+
+// project 形状在轴上的投影区间
+func (c collider) project(axis vec) (float64, float64) {
+	if c.shape == ShapeCircle {
+		center := c.cx*axis.x + c.cy*axis.y
+		r := c.r * math.Hypot(axis.x, axis.y)
+		return center - r, center + r
+	}
+	lo, hi := math.MaxFloat64, -math.MaxFloat64
+	for _, v := range c.vertices() {
+		d := v.x*axis.x + v.y*axis.y
+		lo, hi = math.Min(lo, d), math.Max(hi, d)
+	}
+	return lo, hi
+}
+
+func edgeNormals(pts []vec) []vec {
+	normals := make([]vec, 0, len(pts))
+	for i := range pts {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		normals = append(normals, vec{a.y - b.y, b.x - a.x})
+	}
+	return normals
+}
+
+// target 可被子弹命中的对象
+type target struct {
+	player *model.Player
+	enemy  *model.Enemy
+	col    collider
+	bounds collider // 放入网格的范围，玩家包含可回溯的历史位置
+}
+
+// spatialGrid 均匀网格宽阶段，每个格子记录与之相交的目标下标
+type spatialGrid struct {
+	cols, rows int
+	cells      [][]int
+	targets    []target
+	stamp      []int // 去重用，记录目标最后一次被查询到的序号
+	query      int
+}
+
+func newSpatialGrid(width, height int) *spatialGrid {
+	cols := (width + gridCellSize - 1) / gridCellSize
+	rows := (height + gridCellSize - 1) / gridCellSize
+	return &spatialGrid{cols: cols, rows: rows, cells: make([][]int, cols*rows)}
+}
+
+// cellRange 包围盒覆盖的格子范围，场地外的部分并入边缘格子
+func (g *spatialGrid) cellRange(c collider) (int, int, int, int) {
+	clamp := func(v float64, n int) int {
+		return clampInt(int(math.Floor(v/gridCellSize)), 0, n-1)
+	}
+	return clamp(c.minX, g.cols), clamp(c.minY, g.rows), clamp(c.maxX, g.cols), clamp(c.maxY, g.rows)
+}
+
+func (g *spatialGrid) insert(t target) {
+	idx := len(g.targets)
+	g.targets = append(g.targets, t)
+	g.stamp = append(g.stamp, 0)
+	x0, y0, x1, y1 := g.cellRange(t.bounds)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			g.cells[y*g.cols+x] = append(g.cells[y*g.cols+x], idx)
+		}
+	}
+}
+
+// each 遍历可能与 c 相交的目标，fn 返回 false 时停止
+func (g *spatialGrid) each(c collider, fn func(t *target) bool) {
+	g.query++
+	x0, y0, x1, y1 := g.cellRange(c)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			for _, idx := range g.cells[y*g.cols+x] {
+				if g.stamp[idx] == g.query {
+					continue
+				}
+				g.stamp[idx] = g.query
+				if !fn(&g.targets[idx]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// buildTargetGrid 将本 tick 的存活玩家与敌机放入网格
+func buildTargetGrid(room *model.Room) *spatialGrid {
+	arena := ArenaOf(room)
+	g := newSpatialGrid(arena.Width, arena.Height)
+	for _, p := range room.Players {
+		if p.HP > 0 {
+			col := playerCollider(p)
+			g.insert(target{player: p, col: col, bounds: historyBounds(room, p, col)})
+		}
+	}
+	for _, e := range room.Enemies {
+		col := enemyCollider(room, e)
+		g.insert(target{enemy: e, col: col, bounds: col})
+	}
+	return g
+}
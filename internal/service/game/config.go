@@ -0,0 +1,29 @@
+package game
+
+import "path/filepath"
+
+// LoadGameData 加载 dir 目录下的全部游戏数据配置
+func LoadGameData(dir string) error {
+	if err := LoadRates(filepath.Join(dir, "room.yaml")); err != nil {
+		return err
+	}
+	if err := LoadWeapons(filepath.Join(dir, "weapons.yaml")); err != nil {
+		return err
+	}
+	if err := LoadAbilities(filepath.Join(dir, "abilities.yaml")); err != nil {
+		return err
+	}
+	if err := LoadPlanes(filepath.Join(dir, "planes.yaml")); err != nil {
+		return err
+	}
+	if err := LoadArenas(filepath.Join(dir, "arenas")); err != nil {
+		return err
+	}
+	if err := LoadPowerUps(filepath.Join(dir, "powerups.yaml")); err != nil {
+		return err
+	}
+	if err := LoadWaveScripts(filepath.Join(dir, "waves")); err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,87 +2,196 @@ package game
 
 import (
 	"encoding/json"
-	"github.com/gorilla/websocket"
 	"log"
 	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
 	"time"
 )
 
+// StartRoomLoop 启动房间循环，房间先进入倒计时，到达 start_at 后开始对局；分出结果后保存战绩并移除房间
 func StartRoomLoop(room *model.Room) {
-	ticker := time.NewTicker(50 * time.Millisecond)
-	quit := make(chan bool)
+	room.Lock.Lock()
+	if !setStatus(room, ctype.GameCountdown) {
+		room.Lock.Unlock()
+		return
+	}
+	now := time.Now()
+	room.StartAt = now.Add(CountdownDuration)
+	updateCountdown(room, now)
+	ticker := time.NewTicker(tickInterval(room))
+	room.Ticker = ticker
+	room.Lock.Unlock()
 
 	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				room.Lock.Lock()
-				// 更新子弹位置,并进行碰撞检测
-				newBullets := []*model.Bullet{}
-				for _, bullet := range room.Bullets {
-					bullet.Y -= bullet.Speed
-					hit := false
-					for _, p := range room.Players {
-						if p.ID != bullet.Owner && checkCollision(bullet, p) {
-							p.HP -= bullet.Damage
-							hit = true
-							break
-						}
-					}
-					if !hit && bullet.Y >= 0 {
-						newBullets = append(newBullets, bullet)
-					}
-				}
-				room.Bullets = newBullets
-				//检测玩家存活情况
-				alivePlayers := []*model.Player{}
-				var winner *model.Player
-				for _, player := range room.Players {
-					if player.HP > 0 {
-						alivePlayers = append(alivePlayers, player)
-					}
-
-				}
-				if len(alivePlayers) <= 1 {
-					if len(alivePlayers) == 1 {
-						winner = alivePlayers[0]
-					}
-					broadcastGameOver(room, winner)
-					room.Lock.Unlock()
-					ticker.Stop()
-					return
+		defer ticker.Stop()
+		frame := 0
+		for range ticker.C {
+			room.Lock.Lock()
+			now := time.Now()
+			if room.Status == ctype.GameCountdown {
+				updateCountdown(room, now)
+			}
+			// 投降、投票与暂停先于模拟结算，暂停期间只同步状态
+			updateVotes(room, now)
+			if room.Status == ctype.GameRunning {
+				room.Tick++
+				moveObstacles(room)
+				expireEffects(room, now)
+				useAbilities(room, now)
+				movePlayers(room)
+				// 处理开火请求，射速与弹药在这里统一校验
+				fireWeapons(room, now)
+				// 合作模式：敌机生成、移动与开火
+				if room.Mode == ctype.ModeCoop {
+					updatePvE(room, now)
 				}
-				//广播房间 状态
-				broadcastRoomState(room)
+				// 更新子弹位置,并进行碰撞检测
+				room.Bullets = updateBullets(room)
+				// 道具刷新与拾取
+				spawnPowerUps(room, now)
+				collectPowerUps(room, now)
+				recordHistory(room)
+			}
+			//检测对局是否结束
+			if checkGameOver(room, now) {
+				finishRoom(room, now)
 				room.Lock.Unlock()
-
-			case <-quit:
-				ticker.Stop()
 				return
 			}
+			//按发送频率广播房间状态
+			if frame%sendEvery(room) == 0 {
+				broadcastRoomState(room, now)
+			}
+			frame++
+			room.Lock.Unlock()
 		}
 	}()
 }
 
-func broadcastRoomState(room *model.Room) {
-	state := map[string]interface{}{
-		"type":    "game_state",
-		"players": room.Players,
-		"bullets": room.Bullets,
+// checkGameOver 判断对局是否结束，结束时广播结果；多局对战中一局结束后进入下一局
+func checkGameOver(room *model.Room, now time.Time) bool {
+	if room.EndReason == EndDraw {
+		broadcastGameOver(room, nil)
+		return true
+	}
+	if room.Mode == ctype.ModeCoop {
+		// 合作模式任意玩家投降即全队失败
+		if len(standing(room)) < len(room.Players) {
+			room.EndReason = EndSurrender
+			broadcastCoopOver(room, false)
+			return true
+		}
+		if room.Status == ctype.GameRunning && roundTimeUp(room, now) {
+			room.EndReason = EndTimeout
+			broadcastCoopOver(room, false)
+			return true
+		}
+		over, victory := pveFinished(room)
+		if over {
+			room.Victory = victory
+			broadcastCoopOver(room, victory)
+		}
+		return over
+	}
+
+	// 只剩一名（或没有）未投降的玩家时直接结束整场对局，否则投降只算本局被击败
+	if rest := standing(room); len(rest) <= 1 {
+		room.EndReason = EndSurrender
+		room.Winner = nil
+		if len(rest) == 1 {
+			room.Winner = rest[0]
+		}
+		broadcastGameOver(room, room.Winner)
+		return true
 	}
-	data, _ := json.Marshal(state)
 
+	//检测玩家存活情况，到达时间上限时血量比例最高者赢下本局
+	alivePlayers := []*model.Player{}
+	var winner *model.Player
 	for _, player := range room.Players {
-		player.Conn.WriteMessage(websocket.TextMessage, data)
+		if player.HP > 0 {
+			alivePlayers = append(alivePlayers, player)
+		}
+	}
+	timeUp := room.Status == ctype.GameRunning && roundTimeUp(room, now)
+	if len(alivePlayers) > 1 && !timeUp {
+		return false
+	}
+	if len(alivePlayers) == 1 {
+		winner = alivePlayers[0]
+	} else if len(alivePlayers) > 1 {
+		winner = hpLeader(alivePlayers)
 	}
+	if endRound(room, winner, now) {
+		return false
+	}
+	if timeUp {
+		room.EndReason = EndTimeout
+	}
+	room.Winner = matchWinner(room)
+	broadcastGameOver(room, room.Winner)
+	return true
 }
 
-func checkCollision(b *model.Bullet, p *model.Player) bool {
-	// 简单矩形碰撞
-	if b.X >= p.X && b.X <= p.X+50 && b.Y >= p.Y && b.Y <= p.Y+50 {
-		return true
+// broadcastRoomState 广播房间快照，附带 tick、服务端时间与频率，便于客户端缓冲插值
+func broadcastRoomState(room *model.Room, now time.Time) {
+	state := map[string]interface{}{
+		"type":        "game_state",
+		"tick":        room.Tick,
+		"server_time": now.UnixMilli(),
+		"tick_rate":   room.TickRate,
+		"send_rate":   room.SendRate,
+		"status":      room.Status,
+		"players":     room.Players,
+		"bullets":     room.Bullets,
+		"powerups":    room.PowerUps,
+	}
+	if len(room.Obstacles) > 0 {
+		state["obstacles"] = room.Obstacles
+	}
+	if room.Vote != nil {
+		state["vote"] = room.Vote
+	}
+	if room.Status == ctype.GamePaused {
+		state["pause_end"] = room.PauseEnd.UnixMilli()
+	}
+	if room.Rounds > 1 {
+		state["round"] = room.Round
+		state["rounds"] = room.Rounds
+		state["wins"] = room.RoundWins
+	}
+	if !room.RoundEnd.IsZero() {
+		state["round_end"] = room.RoundEnd.UnixMilli()
+	}
+	if room.Mode == ctype.ModeCoop {
+		state["enemies"] = room.Enemies
+		state["pve"] = room.PvE
+	}
+	broadcast(room, state)
+}
+
+// planeCenter 飞机碰撞盒中心
+func planeCenter(p *model.Player) (float64, float64) {
+	return float64(p.X) + float64(p.Width)/2, float64(p.Y) + float64(p.Height)/2
+}
+
+// overlapPlane 判断矩形与飞机碰撞盒是否相交
+func overlapPlane(p *model.Player, x, y, w, h float64) bool {
+	px, py := float64(p.X), float64(p.Y)
+	return x < px+float64(p.Width) && x+w > px && y < py+float64(p.Height) && y+h > py
+}
+
+// applyDamage 对玩家造成伤害，无敌时免疫，护盾优先吸收
+func applyDamage(p *model.Player, damage int) {
+	if hasEffect(p, EffectInvulnerable) {
+		return
+	}
+	if p.Shield > 0 {
+		absorbed := min(p.Shield, damage)
+		p.Shield -= absorbed
+		damage -= absorbed
 	}
-	return false
+	p.HP -= damage
 }
 
 // 广播游戏结束状态
@@ -91,9 +200,34 @@ func broadcastGameOver(room *model.Room, winner *model.Player) {
 		"type":   "game_over",
 		"winner": winner,
 	}
-	data, _ := json.Marshal(state)
-	for _, player := range room.Players {
-		player.Conn.WriteMessage(websocket.TextMessage, data)
+	if room.EndReason != "" {
+		state["reason"] = room.EndReason
 	}
+	broadcast(room, state)
 	log.Printf("房间 %s 游戏结束，胜利者: %v", room.ID, winner)
 }
+
+// 广播合作模式结束：击败最终波次的 Boss 为胜利，全员阵亡为失败
+func broadcastCoopOver(room *model.Room, victory bool) {
+	state := map[string]interface{}{
+		"type":    "game_over",
+		"mode":    room.Mode,
+		"victory": victory,
+		"pve":     room.PvE,
+	}
+	if room.EndReason != "" {
+		state["reason"] = room.EndReason
+	}
+	broadcast(room, state)
+	log.Printf("房间 %s 合作模式结束，胜利: %v，波次: %d，得分: %d", room.ID, victory, room.PvE.Wave, room.PvE.Score)
+}
+
+// broadcast 向房间内全部玩家发送消息，消息进入各连接的发送队列，由 WritePump 统一写出
+func broadcast(room *model.Room, v interface{}) {
+	data, _ := json.Marshal(v)
+	for _, player := range room.Players {
+		if player.Send != nil {
+			player.Send(data)
+		}
+	}
+}
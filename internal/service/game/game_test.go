@@ -0,0 +1,122 @@
+package game
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"testing"
+	"time"
+)
+
+// TestMain 测试前加载游戏配置
+func TestMain(m *testing.M) {
+	if err := LoadGameData("../../etc"); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestRoom 创建已初始化的对战房间，玩家没有连接
+func newTestRoom(id string, players int) *model.Room {
+	room := &model.Room{ID: id}
+	for i := 0; i < players; i++ {
+		room.Players = append(room.Players, &model.Player{
+			ID:   fmt.Sprintf("%s-p%d", id, i),
+			Name: fmt.Sprintf("p%d", i),
+		})
+	}
+	InitRoom(room)
+	return room
+}
+
+// TestSurrenderInMultiRoundMatch 多人多局对战中一人投降只算本局被击败，只剩一名未投降的玩家时才结束整场对局
+func TestSurrenderInMultiRoundMatch(t *testing.T) {
+	room := &model.Room{ID: "surrender", Rounds: 3}
+	for i := 0; i < 3; i++ {
+		room.Players = append(room.Players, &model.Player{ID: fmt.Sprintf("surrender-p%d", i)})
+	}
+	InitRoom(room)
+	room.Status = ctype.GameRunning
+	now := time.Now()
+
+	a, b, c := room.Players[0], room.Players[1], room.Players[2]
+	if err := RequestMatchAction(room, a, "surrender"); err != nil {
+		t.Fatal(err)
+	}
+	updateVotes(room, now)
+	if checkGameOver(room, now) {
+		t.Fatal("还有两名玩家未投降，对局不应结束")
+	}
+	if a.HP != 0 {
+		t.Fatal("投降的玩家本局应被击败")
+	}
+
+	// b 赢下第一局后进入第二局，投降的 a 依然出局
+	c.HP = 0
+	if checkGameOver(room, now) {
+		t.Fatal("第一局结束后应进入下一局")
+	}
+	if room.Round != 2 || room.RoundWins[b.ID] != 1 {
+		t.Fatalf("应进入第 2 局且 b 赢下 1 局，实际第 %d 局，b 赢 %d 局", room.Round, room.RoundWins[b.ID])
+	}
+	room.Status = ctype.GameRunning
+	updateVotes(room, now)
+	if a.HP != 0 {
+		t.Fatal("投降的玩家在之后的局中也不参与")
+	}
+
+	if err := RequestMatchAction(room, c, "surrender"); err != nil {
+		t.Fatal(err)
+	}
+	updateVotes(room, now)
+	if !checkGameOver(room, now) {
+		t.Fatal("只剩一名未投降的玩家，对局应结束")
+	}
+	if room.Winner != b || room.EndReason != EndSurrender {
+		t.Fatalf("胜者应为 b 且原因为投降，实际 %v %q", room.Winner, room.EndReason)
+	}
+}
+
+// TestLoadWeaponsRejectsInvalid 子弹形状只能是碰撞盒或圆形，开火间隔必须大于 0
+func TestLoadWeaponsRejectsInvalid(t *testing.T) {
+	saved, savedDefault := Weapons, DefaultWeapon
+	defer func() { Weapons, DefaultWeapon = saved, savedDefault }()
+
+	for name, weapon := range map[string]string{
+		"polygon":  "shape: polygon\n    fire_interval: 200ms",
+		"typo":     "shape: circel\n    fire_interval: 200ms",
+		"interval": "fire_interval: 0s",
+	} {
+		file := filepath.Join(t.TempDir(), "weapons.yaml")
+		data := "default: gun\nweapons:\n  - name: gun\n    magazine_size: 10\n    " + weapon + "\n"
+		if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadWeapons(file); err == nil {
+			t.Errorf("%s: 非法的武器配置应加载失败", name)
+		}
+	}
+}
+
+// TestRewindCappedByPing 回溯不超过射手实测延迟加插值缓冲
+func TestRewindCappedByPing(t *testing.T) {
+	room := newTestRoom("rewind", 2)
+	room.Tick = 100
+	shooter := room.Players[0]
+
+	// 20Hz 下每 tick 50ms，延迟 0 时只允许回溯插值缓冲的 2 个 tick
+	if got := rewindTicks(room, shooter, 90); got != 2 {
+		t.Fatalf("未测得延迟时应回溯 2 个 tick，实际 %d", got)
+	}
+	shooter.Ping = 80
+	if got := rewindTicks(room, shooter, 90); got != 4 {
+		t.Fatalf("延迟 80ms 时应回溯 4 个 tick，实际 %d", got)
+	}
+	shooter.Ping = 1000
+	if got := rewindTicks(room, shooter, 50); got != maxRewindTicks(room) {
+		t.Fatalf("回溯不应超过 MaxRewind，实际 %d", got)
+	}
+}
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"math"
+	"plane_war/internal/model"
+	"time"
+)
+
+// MaxRewind 延迟补偿最多回溯的时间，超过的部分按该值计算
+const MaxRewind = 300 * time.Millisecond
+
+// InterpDelay 客户端插值缓冲的时长，画面比最新快照落后这么久
+const InterpDelay = 100 * time.Millisecond
+
+// maxRewindTicks 最多回溯的 tick 数，也是历史位置保留的条数
+func maxRewindTicks(room *model.Room) uint64 {
+	return uint64(MaxRewind / tickInterval(room))
+}
+
+// rewindTicks 根据射手上报的渲染 tick 计算需要回溯的 tick 数，未上报或超前时不回溯。
+// 上报的 tick 不可信，回溯不超过射手实测的往返延迟加插值缓冲
+func rewindTicks(room *model.Room, shooter *model.Player, renderTick uint64) uint64 {
+	if renderTick == 0 || renderTick >= room.Tick {
+		return 0
+	}
+	allowed := min(time.Duration(shooter.Ping)*time.Millisecond+InterpDelay, MaxRewind)
+	limit := uint64(math.Ceil(float64(allowed) / float64(tickInterval(room))))
+	return min(room.Tick-renderTick, limit, maxRewindTicks(room))
+}
+
+// recordHistory 记录本 tick 结束时存活玩家的位置，只保留可回溯范围内的记录
+func recordHistory(room *model.Room) {
+	positions := make(map[string][2]int, len(room.Players))
+	for _, p := range room.Players {
+		if p.HP > 0 {
+			positions[p.ID] = [2]int{p.X, p.Y}
+		}
+	}
+	room.History = append(room.History, &model.TickPositions{Tick: room.Tick, Positions: positions})
+	if n := len(room.History) - int(maxRewindTicks(room)); n > 0 {
+		room.History = room.History[n:]
+	}
+}
+
+// positionAt 玩家在指定 tick 结束时的位置
+func positionAt(room *model.Room, playerID string, tick uint64) (int, int, bool) {
+	for i := len(room.History) - 1; i >= 0; i-- {
+		h := room.History[i]
+		if h.Tick == tick {
+			pos, ok := h.Positions[playerID]
+			return pos[0], pos[1], ok
+		}
+		if h.Tick < tick {
+			break
+		}
+	}
+	return 0, 0, false
+}
+
+// historyBounds 将玩家当前形状的包围盒扩展到覆盖全部历史位置，保证回溯判定不被网格漏掉
+func historyBounds(room *model.Room, p *model.Player, col collider) collider {
+	bounds := col
+	for _, h := range room.History {
+		pos, ok := h.Positions[p.ID]
+		if !ok {
+			continue
+		}
+		dx, dy := float64(pos[0]-p.X), float64(pos[1]-p.Y)
+		bounds.minX = math.Min(bounds.minX, col.minX+dx)
+		bounds.minY = math.Min(bounds.minY, col.minY+dy)
+		bounds.maxX = math.Max(bounds.maxX, col.maxX+dx)
+		bounds.maxY = math.Max(bounds.maxY, col.maxY+dy)
+	}
+	return bounds
+}
@@ -0,0 +1,119 @@
+package game
+
+import (
+	"log"
+	"plane_war/internal/global"
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"slices"
+	"time"
+)
+
+// CountdownDuration 开局倒计时时长，倒计时期间不接受操作
+const CountdownDuration = 3 * time.Second
+
+// transitions 房间状态允许的流转，任何未结束的状态都可以直接结束，多局对战每局之间重新倒计时
+var transitions = map[ctype.GameStatus][]ctype.GameStatus{
+	ctype.GameCreated:   {ctype.GameCountdown, ctype.GameFinished},
+	ctype.GameCountdown: {ctype.GameRunning, ctype.GameFinished},
+	ctype.GameRunning:   {ctype.GamePaused, ctype.GameCountdown, ctype.GameFinished},
+	ctype.GamePaused:    {ctype.GameRunning, ctype.GameFinished},
+	ctype.GameFinished:  {ctype.GameArchived},
+}
+
+// setStatus 切换房间状态，不允许的流转会被忽略并记录日志，调用方需持有 room.Lock
+func setStatus(room *model.Room, to ctype.GameStatus) bool {
+	if !slices.Contains(transitions[room.Status], to) {
+		log.Printf("房间 %s 不能从 %s 切换到 %s", room.ID, room.Status, to)
+		return false
+	}
+	room.Status = to
+	return true
+}
+
+// updateCountdown 倒计时每过一秒广播一次（3、2、1），到达 start_at 时广播 0 并开始对局
+func updateCountdown(room *model.Room, now time.Time) {
+	remain := room.StartAt.Sub(now)
+	count := int((remain + time.Second - 1) / time.Second)
+	if remain <= 0 {
+		count = 0
+	}
+	if count == room.Countdown && room.Countdown != 0 {
+		return
+	}
+	room.Countdown = count
+	broadcast(room, map[string]interface{}{
+		"type":        "countdown",
+		"count":       count,
+		"start_at":    room.StartAt.UnixMilli(),
+		"server_time": now.UnixMilli(),
+		"round":       room.Round,
+	})
+	if count == 0 {
+		setStatus(room, ctype.GameRunning)
+		if room.StartedAt.IsZero() {
+			room.StartedAt = now
+		}
+		if room.TimeLimit > 0 {
+			room.RoundEnd = now.Add(room.TimeLimit)
+		}
+	}
+}
+
+// InputAllowed 房间当前是否接受移动、开火等操作，倒计时与暂停期间拒绝，调用方需持有 room.Lock
+func InputAllowed(room *model.Room) bool {
+	return room.Status == ctype.GameRunning
+}
+
+// finishRoom 对局分出结果后异步保存战绩、移除房间并归档，调用方需持有 room.Lock
+func finishRoom(room *model.Room, now time.Time) {
+	if !setStatus(room, ctype.GameFinished) {
+		return
+	}
+	room.EndedAt = now
+	room.Vote = nil
+	// 结果在锁内复制，写库放到协程里，避免数据库变慢时卡住房间锁
+	if global.DB != nil {
+		result := matchResult(room)
+		go func() {
+			if err := global.DB.Create(&result).Error; err != nil {
+				log.Printf("保存房间 %s 的对局结果失败: %v", result.RoomID, err)
+			}
+		}()
+	}
+	removeRoom(room)
+	setStatus(room, ctype.GameArchived)
+	if room.Quit != nil {
+		close(room.Quit)
+	}
+}
+
+// matchResult 根据房间生成对局结果记录，调用方需持有 room.Lock
+func matchResult(room *model.Room) model.MatchResult {
+	result := model.MatchResult{
+		RoomID:    room.ID,
+		Mode:      room.Mode,
+		Arena:     room.Arena,
+		Victory:   room.Victory,
+		Reason:    room.EndReason,
+		Ticks:     room.Tick,
+		StartedAt: room.StartedAt,
+		EndedAt:   room.EndedAt,
+	}
+	if room.Winner != nil {
+		result.WinnerID = room.Winner.UserID
+	}
+	if room.PvE != nil {
+		result.Score = room.PvE.Score
+	}
+	for _, p := range room.Players {
+		result.Players = append(result.Players, model.MatchPlayer{
+			UserID:    p.UserID,
+			Name:      p.Name,
+			Plane:     p.Plane,
+			HP:        max(p.HP, 0),
+			Surrender: p.Surrender,
+		})
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package game
+
+import (
+	"math"
+	"plane_war/internal/model"
+)
+
+// RequestMove 记录玩家想要到达的位置，实际位移由游戏循环按速度逼近，调用方需持有 room.Lock
+func RequestMove(room *model.Room, p *model.Player, x, y int) {
+	arena := ArenaOf(room)
+	p.TargetX = clampInt(x, 0, arena.Width-p.Width)
+	p.TargetY = clampInt(y, 0, arena.Height-p.Height)
+}
+
+// movePlayers 将所有玩家向目标位置移动，单 tick 位移不超过当前速度
+func movePlayers(room *model.Room) {
+	for _, p := range room.Players {
+		if p.HP <= 0 {
+			continue
+		}
+		speed := float64(planeSpeed(p)) * tickScale(room)
+		if hasEffect(p, PowerUpSpeedBoost) {
+			if def, ok := powerUpDef(PowerUpSpeedBoost); ok {
+				speed *= 1 + float64(def.Amount)/100
+			}
+		}
+		dx := float64(p.TargetX - p.X)
+		dy := float64(p.TargetY - p.Y)
+		dist := math.Hypot(dx, dy)
+		nx, ny := p.TargetX, p.TargetY
+		if dist > speed {
+			nx = p.X + int(math.Round(dx/dist*speed))
+			ny = p.Y + int(math.Round(dy/dist*speed))
+		}
+		moveAround(room, p, nx, ny)
+	}
+}
+
+// moveAround 移动飞机，撞上障碍物时尝试沿单轴滑动；已经与障碍物重叠（被移动障碍物压住）时允许脱离
+func moveAround(room *model.Room, p *model.Player, nx, ny int) {
+	if !planeBlocked(room, p, nx, ny) || planeBlocked(room, p, p.X, p.Y) {
+		p.X, p.Y = nx, ny
+		return
+	}
+	if !planeBlocked(room, p, nx, p.Y) {
+		p.X = nx
+		return
+	}
+	if !planeBlocked(room, p, p.X, ny) {
+		p.Y = ny
+	}
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
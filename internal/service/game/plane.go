@@ -0,0 +1,180 @@
+package game
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"log"
+	"math/rand"
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"slices"
+	"time"
+)
+
+// Plane 飞机机型参数，由 internal/etc/planes.yaml 定义
+type Plane struct {
+	Name      string   `mapstructure:"name"`
+	HP        int      `mapstructure:"hp"`        // 血量上限
+	Speed     int      `mapstructure:"speed"`     // 每 tick 最大移动距离
+	Width     int      `mapstructure:"width"`     // 碰撞盒宽度
+	Height    int      `mapstructure:"height"`    // 碰撞盒高度
+	Hitbox    Hitbox   `mapstructure:"hitbox"`    // 碰撞形状，多边形按机头朝上定义
+	Weapons   []string `mapstructure:"weapons"`   // 可挂载的武器，第一个为默认武器
+	Abilities []string `mapstructure:"abilities"` // 可使用的特殊技能
+}
+
+var (
+	// Planes 机型列表
+	Planes = map[string]Plane{}
+	// DefaultPlane 未选择机型时使用
+	DefaultPlane string
+)
+
+// LoadPlanes 从配置文件加载机型定义，武器与技能需先加载
+func LoadPlanes(file string) error {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("读取机型配置失败: %v", err)
+	}
+	var list []Plane
+	if err := v.UnmarshalKey("planes", &list); err != nil {
+		return fmt.Errorf("解析机型配置失败: %v", err)
+	}
+
+	planes := make(map[string]Plane, len(list))
+	for _, p := range list {
+		if p.Name == "" {
+			return fmt.Errorf("机型缺少 name")
+		}
+		if p.HP <= 0 || p.Speed <= 0 || p.Width <= 0 || p.Height <= 0 {
+			return fmt.Errorf("机型 %s 的 hp/speed/width/height 必须大于 0", p.Name)
+		}
+		if len(p.Weapons) == 0 {
+			return fmt.Errorf("机型 %s 至少需要一把武器", p.Name)
+		}
+		for _, w := range p.Weapons {
+			if _, ok := Weapons[w]; !ok {
+				return fmt.Errorf("机型 %s 的武器 %s 不存在", p.Name, w)
+			}
+		}
+		if err := validHitbox(p.Hitbox); err != nil {
+			return fmt.Errorf("机型 %s 的 hitbox 无效: %v", p.Name, err)
+		}
+		for _, a := range p.Abilities {
+			if _, ok := Abilities[a]; !ok {
+				return fmt.Errorf("机型 %s 的技能 %s 不存在", p.Name, a)
+			}
+		}
+		planes[p.Name] = p
+	}
+	def := v.GetString("default")
+	if _, ok := planes[def]; !ok {
+		return fmt.Errorf("默认机型 %s 不存在", def)
+	}
+
+	Planes = planes
+	DefaultPlane = def
+	return nil
+}
+
+// ValidateLoadout 校验玩家选择的机型与武器，为空表示使用默认值，返回实际使用的机型
+func ValidateLoadout(plane, weapon string) (string, error) {
+	if plane == "" {
+		plane = DefaultPlane
+	}
+	def, ok := Planes[plane]
+	if !ok {
+		return "", fmt.Errorf("机型 %s 不存在", plane)
+	}
+	if weapon != "" && !slices.Contains(def.Weapons, weapon) {
+		return "", fmt.Errorf("机型 %s 不能挂载武器 %s", plane, weapon)
+	}
+	return plane, nil
+}
+
+// InitRoom 按机型初始化房间内的玩家并按场地分配出生点，调用方需在广播 match_success 之前调用。
+// 对战模式玩家交替分布在上下两侧，合作模式全部使用下方出生点
+func InitRoom(room *model.Room) {
+	room.Lock.Lock()
+	defer room.Lock.Unlock()
+
+	if room.Mode == "" {
+		room.Mode = ctype.ModePvP
+	}
+	if room.TickRate == 0 {
+		// 默认频率在加载配置时已校验，失败只可能是配置被改坏，退回基准频率
+		if err := SetRates(room, 0, 0); err != nil {
+			log.Printf("房间 %s 使用默认频率失败: %v", room.ID, err)
+			room.TickRate, room.SendRate = BaseTickRate, BaseTickRate
+		}
+	}
+	room.Status = ctype.GameCreated
+	if room.Quit == nil {
+		room.Quit = make(chan bool)
+	}
+	if room.Rand == nil {
+		room.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if room.Mode == ctype.ModeCoop {
+		initPvE(room)
+	}
+	if room.Rounds < 1 {
+		room.Rounds = 1
+	}
+	room.Round = 1
+	room.RoundWins = make(map[string]int)
+	initObstacles(room)
+	spawnPlayers(room)
+	for _, p := range room.Players {
+		p.Surrender = false
+		p.PauseLeft = PauseBudget.Milliseconds()
+	}
+}
+
+// spawnPlayers 按机型重置玩家并放到出生点，对战模式上下交替
+func spawnPlayers(room *model.Room) {
+	arena := ArenaOf(room)
+	top, bottom := 0, 0
+	for i, p := range room.Players {
+		initPlane(p)
+		if room.Mode != ctype.ModeCoop && i%2 == 0 {
+			p.X, p.Y = spawnPoint(arena.Spawns.Top, top, p)
+			p.Position = "top"
+			top++
+		} else {
+			p.X, p.Y = spawnPoint(arena.Spawns.Bottom, bottom, p)
+			p.Position = "bottom"
+			bottom++
+		}
+		p.TargetX, p.TargetY = p.X, p.Y
+	}
+}
+
+// initPlane 按机型重置玩家的血量、碰撞盒、武器与技能
+func initPlane(p *model.Player) {
+	def, ok := Planes[p.Plane]
+	if !ok {
+		def = Planes[DefaultPlane]
+	}
+	weapon := def.Weapons[0]
+	if slices.Contains(def.Weapons, p.Loadout) {
+		weapon = p.Loadout
+	}
+
+	p.Plane = def.Name
+	p.HP = def.HP
+	p.MaxHP = def.HP
+	p.Speed = def.Speed
+	p.Width = def.Width
+	p.Height = def.Height
+	p.Weapon = NewWeaponState(weapon)
+	p.Shield = 0
+	p.Effects = nil
+	p.Abilities = newAbilityStates(def.Abilities)
+}
+
+// planeSpeed 玩家当前机型每 tick 的最大移动距离，开局时由 initPlane 写入
+func planeSpeed(p *model.Player) int {
+	return p.Speed
+}
@@ -0,0 +1,208 @@
+package game
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"plane_war/internal/model"
+	"time"
+)
+
+// 道具类型
+const (
+	PowerUpShield     = "shield"      // 护盾，吸收伤害
+	PowerUpHeal       = "heal"        // 立即回血
+	PowerUpTripleShot = "triple_shot" // 三倍弹幕
+	PowerUpSpeedBoost = "speed_boost" // 移动加速
+)
+
+// PowerUpDef 单个道具的参数
+type PowerUpDef struct {
+	Kind     string        `mapstructure:"kind"`
+	Weight   int           `mapstructure:"weight"`   // 刷新权重
+	Amount   int           `mapstructure:"amount"`   // 护盾值/回血量/加速百分比
+	Duration time.Duration `mapstructure:"duration"` // 效果持续时间，回血无需配置
+}
+
+// PowerUpConfig 道具刷新规则，由 internal/etc/powerups.yaml 定义
+type PowerUpConfig struct {
+	SpawnInterval time.Duration `mapstructure:"spawn_interval"` // 刷新间隔
+	MaxOnField    int           `mapstructure:"max_on_field"`   // 场上同时存在的最大数量
+	Lifetime      time.Duration `mapstructure:"lifetime"`       // 未被拾取时的存在时间
+	Size          float64       `mapstructure:"size"`           // 道具碰撞盒边长
+	PowerUps      []PowerUpDef  `mapstructure:"powerups"`
+}
+
+// PowerUps 道具配置，未加载时不刷新道具
+var PowerUps PowerUpConfig
+
+// LoadPowerUps 从配置文件加载道具定义
+func LoadPowerUps(file string) error {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("读取道具配置失败: %v", err)
+	}
+	var cfg PowerUpConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return fmt.Errorf("解析道具配置失败: %v", err)
+	}
+	for _, d := range cfg.PowerUps {
+		switch d.Kind {
+		case PowerUpShield, PowerUpHeal, PowerUpTripleShot, PowerUpSpeedBoost:
+		default:
+			return fmt.Errorf("未知的道具类型: %s", d.Kind)
+		}
+		if d.Weight <= 0 {
+			return fmt.Errorf("道具 %s 的 weight 必须大于 0", d.Kind)
+		}
+	}
+	if cfg.SpawnInterval <= 0 {
+		return fmt.Errorf("spawn_interval 必须大于 0")
+	}
+	PowerUps = cfg
+	return nil
+}
+
+// spawnPowerUps 按刷新间隔在随机位置生成道具
+func spawnPowerUps(room *model.Room, now time.Time) {
+	if len(PowerUps.PowerUps) == 0 {
+		return
+	}
+	if room.NextDrop.IsZero() {
+		room.NextDrop = now.Add(PowerUps.SpawnInterval)
+		return
+	}
+	if now.Before(room.NextDrop) {
+		return
+	}
+	room.NextDrop = now.Add(PowerUps.SpawnInterval)
+	if len(room.PowerUps) >= PowerUps.MaxOnField {
+		return
+	}
+
+	total := 0
+	for _, d := range PowerUps.PowerUps {
+		total += d.Weight
+	}
+	pick := room.Rand.Intn(total)
+	def := PowerUps.PowerUps[0]
+	for _, d := range PowerUps.PowerUps {
+		if pick < d.Weight {
+			def = d
+			break
+		}
+		pick -= d.Weight
+	}
+
+	// 道具刷在场地中间区域，双方距离相近，避开障碍物
+	arena := ArenaOf(room)
+	size := PowerUps.Size
+	w, h := float64(arena.Width), float64(arena.Height)
+	for try := 0; try < 5; try++ {
+		x := room.Rand.Float64() * (w - size)
+		y := h/4 + room.Rand.Float64()*(h/2-size)
+		if hitObstacle(room, x, y, size, size) {
+			continue
+		}
+		room.PowerUps = append(room.PowerUps, &model.PowerUp{
+			ID:        uuid.New().String(),
+			Kind:      def.Kind,
+			X:         x,
+			Y:         y,
+			Size:      size,
+			ExpiresAt: now.Add(PowerUps.Lifetime).UnixMilli(),
+		})
+		return
+	}
+}
+
+// collectPowerUps 处理玩家拾取道具以及过期道具的清理
+func collectPowerUps(room *model.Room, now time.Time) {
+	remain := room.PowerUps[:0]
+	for _, pu := range room.PowerUps {
+		if now.UnixMilli() >= pu.ExpiresAt {
+			continue
+		}
+		var picker *model.Player
+		for _, p := range room.Players {
+			if p.HP > 0 && overlapPlane(p, pu.X, pu.Y, pu.Size, pu.Size) {
+				picker = p
+				break
+			}
+		}
+		if picker == nil {
+			remain = append(remain, pu)
+			continue
+		}
+		applyPowerUp(picker, pu.Kind, now)
+	}
+	room.PowerUps = remain
+}
+
+// applyPowerUp 让道具效果作用到玩家身上，同类效果重复拾取时刷新持续时间
+func applyPowerUp(p *model.Player, kind string, now time.Time) {
+	def, ok := powerUpDef(kind)
+	if !ok {
+		return
+	}
+	switch kind {
+	case PowerUpHeal:
+		p.HP += def.Amount
+		if p.HP > p.MaxHP {
+			p.HP = p.MaxHP
+		}
+		return
+	case PowerUpShield:
+		p.Shield = def.Amount
+	}
+
+	addEffect(p, kind, now.Add(def.Duration))
+}
+
+// addEffect 给玩家添加效果，已有同类效果时刷新失效时间
+func addEffect(p *model.Player, kind string, expiresAt time.Time) {
+	expires := expiresAt.UnixMilli()
+	for _, e := range p.Effects {
+		if e.Kind == kind {
+			e.ExpiresAt = max(e.ExpiresAt, expires)
+			return
+		}
+	}
+	p.Effects = append(p.Effects, &model.Effect{Kind: kind, ExpiresAt: expires})
+}
+
+// expireEffects 移除已失效的效果，护盾被打空时同样失效
+func expireEffects(room *model.Room, now time.Time) {
+	for _, p := range room.Players {
+		remain := p.Effects[:0]
+		for _, e := range p.Effects {
+			if now.UnixMilli() >= e.ExpiresAt || (e.Kind == PowerUpShield && p.Shield <= 0) {
+				if e.Kind == PowerUpShield {
+					p.Shield = 0
+				}
+				continue
+			}
+			remain = append(remain, e)
+		}
+		p.Effects = remain
+	}
+}
+
+func hasEffect(p *model.Player, kind string) bool {
+	for _, e := range p.Effects {
+		if e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func powerUpDef(kind string) (PowerUpDef, bool) {
+	for _, d := range PowerUps.PowerUps {
+		if d.Kind == kind {
+			return d, true
+		}
+	}
+	return PowerUpDef{}, false
+}
@@ -0,0 +1,367 @@
+package game
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"math"
+	"path/filepath"
+	"plane_war/internal/model"
+	"strings"
+	"time"
+)
+
+// enemyOwner 敌机子弹的 Owner，与玩家 ID 区分
+const enemyOwner = "enemy"
+
+// 敌机路径类型
+const (
+	PathLine  = "line"  // 匀速直线
+	PathSine  = "sine"  // 向下飞行并左右摆动
+	PathHover = "hover" // 飞到 stop_y 后悬停并左右巡逻
+)
+
+// 敌机弹幕类型
+const (
+	FireStraight = "straight" // 垂直向下
+	FireAimed    = "aimed"    // 瞄准最近的玩家
+	FireSpread   = "spread"   // 向下扇形
+	FireRing     = "ring"     // 360 度环形
+)
+
+// FirePattern 敌机开火方式
+type FirePattern struct {
+	Pattern     string        `mapstructure:"pattern"`
+	Interval    time.Duration `mapstructure:"interval"`     // 开火间隔
+	BulletSpeed float64       `mapstructure:"bullet_speed"` // 子弹每 tick 移动距离
+	Damage      int           `mapstructure:"damage"`
+	Count       int           `mapstructure:"count"` // 每次开火的子弹数
+	Angle       float64       `mapstructure:"angle"` // 扇形总角度（度）
+}
+
+// BossPhase Boss 阶段，血量百分比低于 Below 时切换到该阶段的弹幕
+type BossPhase struct {
+	Below int         `mapstructure:"below"`
+	Fire  FirePattern `mapstructure:"fire"`
+}
+
+// EnemyType 敌机类型
+type EnemyType struct {
+	Name   string      `mapstructure:"name"`
+	HP     int         `mapstructure:"hp"`
+	Width  float64     `mapstructure:"width"`
+	Height float64     `mapstructure:"height"`
+	Hitbox Hitbox      `mapstructure:"hitbox"`
+	Score  int         `mapstructure:"score"` // 击毁得分
+	Boss   bool        `mapstructure:"boss"`
+	Fire   FirePattern `mapstructure:"fire"`
+	Phases []BossPhase `mapstructure:"phases"` // 按 Below 从大到小排列
+}
+
+// EnemyPath 敌机飞行路径，速度单位均为每 tick
+type EnemyPath struct {
+	Kind      string  `mapstructure:"kind"`
+	X         float64 `mapstructure:"x"` // 出生点
+	Y         float64 `mapstructure:"y"`
+	VX        float64 `mapstructure:"vx"`
+	VY        float64 `mapstructure:"vy"`
+	Amplitude float64 `mapstructure:"amplitude"` // 左右摆动幅度
+	Frequency float64 `mapstructure:"frequency"` // 摆动角速度（弧度/tick）
+	StopY     float64 `mapstructure:"stop_y"`    // 悬停高度
+}
+
+// WaveSpawn 波次中的一次敌机生成
+type WaveSpawn struct {
+	At   time.Duration `mapstructure:"at"` // 相对波次开始的时间
+	Type string        `mapstructure:"type"`
+	Path EnemyPath     `mapstructure:"path"`
+}
+
+// Wave 一个波次，全部敌机生成并被消灭后进入下一波
+type Wave struct {
+	Spawns []WaveSpawn `mapstructure:"spawns"`
+}
+
+// WaveScript 合作模式脚本，最后一波应当是 Boss 战
+type WaveScript struct {
+	Name    string
+	Enemies map[string]EnemyType
+	Waves   []Wave
+}
+
+var (
+	// WaveScripts 波次脚本，文件名即脚本名
+	WaveScripts = map[string]*WaveScript{}
+	// DefaultScript 合作模式默认脚本
+	DefaultScript = "default"
+)
+
+// LoadWaveScripts 加载 dir 目录下的全部波次脚本
+func LoadWaveScripts(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return fmt.Errorf("读取波次脚本目录失败: %v", err)
+	}
+	scripts := make(map[string]*WaveScript, len(files))
+	for _, file := range files {
+		script, err := loadWaveScript(file)
+		if err != nil {
+			return err
+		}
+		scripts[script.Name] = script
+	}
+	if _, ok := scripts[DefaultScript]; !ok {
+		return fmt.Errorf("缺少默认波次脚本 %s.yaml", DefaultScript)
+	}
+	WaveScripts = scripts
+	return nil
+}
+
+func loadWaveScript(file string) (*WaveScript, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("读取波次脚本 %s 失败: %v", file, err)
+	}
+	var enemies []EnemyType
+	var waves []Wave
+	if err := v.UnmarshalKey("enemies", &enemies); err != nil {
+		return nil, fmt.Errorf("解析波次脚本 %s 失败: %v", file, err)
+	}
+	if err := v.UnmarshalKey("waves", &waves); err != nil {
+		return nil, fmt.Errorf("解析波次脚本 %s 失败: %v", file, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	script := &WaveScript{Name: name, Enemies: make(map[string]EnemyType), Waves: waves}
+	for _, e := range enemies {
+		if e.HP <= 0 {
+			return nil, fmt.Errorf("脚本 %s 中敌机 %s 的 hp 必须大于 0", name, e.Name)
+		}
+		if err := validHitbox(e.Hitbox); err != nil {
+			return nil, fmt.Errorf("脚本 %s 中敌机 %s 的 hitbox 无效: %v", name, e.Name, err)
+		}
+		script.Enemies[e.Name] = e
+	}
+	if len(waves) == 0 {
+		return nil, fmt.Errorf("脚本 %s 没有任何波次", name)
+	}
+	for i, w := range waves {
+		for _, s := range w.Spawns {
+			if _, ok := script.Enemies[s.Type]; !ok {
+				return nil, fmt.Errorf("脚本 %s 第 %d 波的敌机类型 %s 不存在", name, i+1, s.Type)
+			}
+		}
+	}
+	return script, nil
+}
+
+// ScriptExists 判断波次脚本是否存在
+func ScriptExists(name string) bool {
+	_, ok := WaveScripts[name]
+	return ok
+}
+
+// initPvE 初始化合作模式进度
+func initPvE(room *model.Room) {
+	script := DefaultScript
+	if room.PvE != nil && ScriptExists(room.PvE.Script) {
+		script = room.PvE.Script
+	}
+	room.Enemies = nil
+	room.PvE = &model.PvEState{
+		Script: script,
+		Wave:   1,
+		Waves:  len(WaveScripts[script].Waves),
+	}
+}
+
+// updatePvE 推进波次：按时间生成敌机、移动敌机并让其开火
+func updatePvE(room *model.Room, now time.Time) {
+	pve := room.PvE
+	script := WaveScripts[pve.Script]
+	if pve.WaveStart.IsZero() {
+		pve.WaveStart = now
+	}
+
+	wave := script.Waves[pve.Wave-1]
+	elapsed := now.Sub(pve.WaveStart)
+	for pve.Spawned < len(wave.Spawns) && wave.Spawns[pve.Spawned].At <= elapsed {
+		room.Enemies = append(room.Enemies, spawnEnemy(script, pve.Wave-1, pve.Spawned, now))
+		pve.Spawned++
+	}
+
+	arena := ArenaOf(room)
+	remain := room.Enemies[:0]
+	for _, e := range room.Enemies {
+		moveEnemy(script, e, tickScale(room))
+		if e.Y > float64(arena.Height)+100 || e.Y < -200 || e.X < -200 || e.X > float64(arena.Width)+200 {
+			continue // 飞出场地
+		}
+		enemyFire(room, script, e, now)
+		remain = append(remain, e)
+	}
+	room.Enemies = remain
+
+	// 本波敌机全部生成并清空后进入下一波
+	if pve.Spawned == len(wave.Spawns) && len(room.Enemies) == 0 && pve.Wave < pve.Waves {
+		pve.Wave++
+		pve.Spawned = 0
+		pve.WaveStart = now
+	}
+}
+
+func spawnEnemy(script *WaveScript, wave, spawn int, now time.Time) *model.Enemy {
+	s := script.Waves[wave].Spawns[spawn]
+	t := script.Enemies[s.Type]
+	return &model.Enemy{
+		ID:       uuid.New().String(),
+		Type:     t.Name,
+		X:        s.Path.X,
+		Y:        s.Path.Y,
+		Width:    t.Width,
+		Height:   t.Height,
+		HP:       t.HP,
+		MaxHP:    t.HP,
+		Boss:     t.Boss,
+		Wave:     wave,
+		Spawn:    spawn,
+		NextFire: now.Add(t.Fire.Interval),
+	}
+}
+
+// moveEnemy 按路径计算敌机当前位置，路径参数以基准 tick 为单位
+func moveEnemy(script *WaveScript, e *model.Enemy, scale float64) {
+	e.Age++
+	path := script.Waves[e.Wave].Spawns[e.Spawn].Path
+	age := float64(e.Age) * scale
+	switch path.Kind {
+	case PathSine:
+		e.X = path.X + path.Amplitude*math.Sin(age*path.Frequency)
+		e.Y = path.Y + path.VY*age
+	case PathHover:
+		e.Y = path.Y + path.VY*age
+		if path.VY > 0 && e.Y >= path.StopY {
+			arrived := (path.StopY - path.Y) / path.VY
+			e.Y = path.StopY
+			e.X = path.X + path.Amplitude*math.Sin((age-arrived)*path.Frequency)
+		}
+	default:
+		e.X = path.X + path.VX*age
+		e.Y = path.Y + path.VY*age
+	}
+}
+
+// enemyFire 按敌机（或 Boss 当前阶段）的弹幕开火
+func enemyFire(room *model.Room, script *WaveScript, e *model.Enemy, now time.Time) {
+	t := script.Enemies[e.Type]
+	fire := t.Fire
+	for i, phase := range t.Phases {
+		if e.HP*100 <= e.MaxHP*phase.Below {
+			fire = phase.Fire
+			e.Phase = i + 1
+		}
+	}
+	if fire.Interval <= 0 || now.Before(e.NextFire) {
+		return
+	}
+	e.NextFire = now.Add(fire.Interval)
+
+	cx, cy := e.X+e.Width/2, e.Y+e.Height
+	count := max(fire.Count, 1)
+	var angles []float64 // 相对正下方的角度（度）
+	switch fire.Pattern {
+	case FireAimed:
+		target := nearestPlayer(room, cx, cy)
+		if target == nil {
+			return
+		}
+		tx, ty := planeCenter(target)
+		base := math.Atan2(tx-cx, ty-cy) * 180 / math.Pi
+		angles = fanAngles(base, fire.Angle, count)
+	case FireSpread:
+		angles = fanAngles(0, fire.Angle, count)
+	case FireRing:
+		for i := 0; i < count; i++ {
+			angles = append(angles, 360*float64(i)/float64(count))
+		}
+	default:
+		angles = fanAngles(0, 0, count)
+	}
+
+	for _, a := range angles {
+		rad := a * math.Pi / 180
+		room.Bullets = append(room.Bullets, &model.Bullet{
+			ID:     uuid.New().String(),
+			Weapon: e.Type,
+			X:      cx - 3,
+			Y:      cy,
+			VX:     fire.BulletSpeed * math.Sin(rad),
+			VY:     fire.BulletSpeed * math.Cos(rad),
+			Width:  6,
+			Height: 6,
+			Owner:  enemyOwner,
+			Damage: fire.Damage,
+		})
+	}
+}
+
+// fanAngles 以 base 为中心，在 spread 角度内均匀分布 count 个方向
+func fanAngles(base, spread float64, count int) []float64 {
+	if count == 1 {
+		return []float64{base}
+	}
+	angles := make([]float64, 0, count)
+	for i := 0; i < count; i++ {
+		angles = append(angles, base-spread/2+spread*float64(i)/float64(count-1))
+	}
+	return angles
+}
+
+// removeDeadEnemies 移除本 tick 被击毁的敌机
+func removeDeadEnemies(room *model.Room) {
+	remain := room.Enemies[:0]
+	for _, e := range room.Enemies {
+		if e.HP > 0 {
+			remain = append(remain, e)
+		}
+	}
+	room.Enemies = remain
+}
+
+// nearestPlayer 查找离坐标最近的存活玩家
+func nearestPlayer(room *model.Room, x, y float64) *model.Player {
+	var target *model.Player
+	best := math.MaxFloat64
+	for _, p := range room.Players {
+		if p.HP <= 0 {
+			continue
+		}
+		cx, cy := planeCenter(p)
+		if d := (cx-x)*(cx-x) + (cy-y)*(cy-y); d < best {
+			best = d
+			target = p
+		}
+	}
+	return target
+}
+
+// pveFinished 合作模式结束判定：全员阵亡失败，最后一波（Boss）清空则胜利
+func pveFinished(room *model.Room) (over bool, victory bool) {
+	alive := false
+	for _, p := range room.Players {
+		if p.HP > 0 {
+			alive = true
+			break
+		}
+	}
+	if !alive {
+		return true, false
+	}
+	pve := room.PvE
+	wave := WaveScripts[pve.Script].Waves[pve.Wave-1]
+	if pve.Wave == pve.Waves && pve.Spawned == len(wave.Spawns) && len(room.Enemies) == 0 {
+		return true, true
+	}
+	return false, false
+}
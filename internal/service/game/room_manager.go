@@ -0,0 +1,104 @@
+package game
+
+import (
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"sync"
+	"time"
+)
+
+// rooms 房间ID到房间，playerRooms 玩家ID到所在房间，都使用 sync.Map 避免全局锁
+var (
+	rooms       sync.Map
+	playerRooms sync.Map
+)
+
+// RoomInfo 房间概况，供大厅与管理接口查询
+type RoomInfo struct {
+	ID        string           `json:"id"`
+	Mode      ctype.GameMode   `json:"mode"`
+	Arena     string           `json:"arena"`
+	Status    ctype.GameStatus `json:"status"`
+	Tick      uint64           `json:"tick"`
+	Players   []string         `json:"players"` // 玩家昵称
+	StartedAt time.Time        `json:"started_at"`
+}
+
+// AddRoom 登记房间并建立玩家索引，房间结束并保存结果后自动移除
+func AddRoom(room *model.Room) {
+	rooms.Store(room.ID, room)
+	for _, p := range room.Players {
+		playerRooms.Store(p.ID, room)
+	}
+}
+
+// GetRoom 按ID查找房间
+func GetRoom(id string) *model.Room {
+	if v, ok := rooms.Load(id); ok {
+		return v.(*model.Room)
+	}
+	return nil
+}
+
+// removeRoom 移除房间及其玩家索引，玩家已进入新房间时保留新的索引
+func removeRoom(room *model.Room) {
+	rooms.CompareAndDelete(room.ID, room)
+	for _, p := range room.Players {
+		playerRooms.CompareAndDelete(p.ID, room)
+	}
+}
+
+// FindPlayerRoom 查找玩家所在的房间
+func FindPlayerRoom(playerID string) *model.Room {
+	if v, ok := playerRooms.Load(playerID); ok {
+		return v.(*model.Room)
+	}
+	return nil
+}
+
+// ListRooms 当前所有房间的概况
+func ListRooms() []RoomInfo {
+	infos := make([]RoomInfo, 0)
+	rooms.Range(func(_, v any) bool {
+		infos = append(infos, roomInfo(v.(*model.Room)))
+		return true
+	})
+	return infos
+}
+
+// RoomClosed 房间是否已归档，不需要持有 room.Lock
+func RoomClosed(room *model.Room) bool {
+	select {
+	case <-room.Quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetRoomInfo 单个房间的概况，房间不存在时返回 false
+func GetRoomInfo(id string) (RoomInfo, bool) {
+	room := GetRoom(id)
+	if room == nil {
+		return RoomInfo{}, false
+	}
+	return roomInfo(room), true
+}
+
+func roomInfo(room *model.Room) RoomInfo {
+	room.Lock.Lock()
+	defer room.Lock.Unlock()
+	info := RoomInfo{
+		ID:        room.ID,
+		Mode:      room.Mode,
+		Arena:     room.Arena,
+		Status:    room.Status,
+		Tick:      room.Tick,
+		Players:   make([]string, 0, len(room.Players)),
+		StartedAt: room.StartedAt,
+	}
+	for _, p := range room.Players {
+		info.Players = append(info.Players, p.Name)
+	}
+	return info
+}
@@ -0,0 +1,45 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"plane_war/internal/model"
+	"sync/atomic"
+	"testing"
+)
+
+// BenchmarkFindPlayerRoom 5000 个房间同时存在时，并发按玩家查找房间（每条移动、开火消息都会查找一次）
+func BenchmarkFindPlayerRoom(b *testing.B) {
+	const roomCount = 5000
+	var added []*model.Room
+	ids := make([]string, roomCount)
+	for i := 0; i < roomCount; i++ {
+		room := &model.Room{
+			ID: fmt.Sprintf("bench-room-%d", i),
+			Players: []*model.Player{
+				{ID: fmt.Sprintf("bench-room-%d-a", i)},
+				{ID: fmt.Sprintf("bench-room-%d-b", i)},
+			},
+		}
+		AddRoom(room)
+		ids[i] = room.Players[0].ID
+		added = append(added, room)
+	}
+	b.Cleanup(func() {
+		for _, room := range added {
+			removeRoom(room)
+		}
+	})
+
+	var seed atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(seed.Add(1)))
+		for pb.Next() {
+			room := FindPlayerRoom(ids[r.Intn(roomCount)])
+			if room == nil || GetRoom(room.ID) != room {
+				b.Fatal("房间索引不一致")
+			}
+		}
+	})
+}
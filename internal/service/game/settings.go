@@ -0,0 +1,152 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 大厅房间设置的取值范围
+const (
+	MaxRoomPlayers = 4    // 房间最多玩家数
+	MaxRounds      = 5    // 对战模式最多局数
+	MinTimeLimit   = 60   // 每局时间上限的最小值（秒）
+	MaxTimeLimit   = 1800 // 每局时间上限的最大值（秒）
+	MaxRoomName    = 20   // 房间名最多字符数
+)
+
+// EndTimeout 最后一局到达时间上限结束
+const EndTimeout = "timeout"
+
+// ValidateSettings 校验大厅房间设置并补全默认值
+func ValidateSettings(s *model.RoomSettings) error {
+	s.Name = strings.TrimSpace(s.Name)
+	if utf8.RuneCountInString(s.Name) > MaxRoomName {
+		return fmt.Errorf("房间名不能超过 %d 个字", MaxRoomName)
+	}
+	if s.Mode == "" {
+		s.Mode = ctype.ModePvP
+	}
+	minPlayers := 2
+	switch s.Mode {
+	case ctype.ModePvP:
+	case ctype.ModeCoop:
+		minPlayers = 1
+	default:
+		return fmt.Errorf("未知的游戏模式 %s", s.Mode)
+	}
+	if s.Arena != "" && !ArenaExists(s.Arena) {
+		return fmt.Errorf("场地 %s 不存在", s.Arena)
+	}
+	if s.Capacity < minPlayers || s.Capacity > MaxRoomPlayers {
+		return fmt.Errorf("房间人数需为 %d~%d", minPlayers, MaxRoomPlayers)
+	}
+	if s.Rounds == 0 {
+		s.Rounds = 1
+	}
+	if s.Rounds < 1 || s.Rounds > MaxRounds {
+		return fmt.Errorf("局数需为 1~%d", MaxRounds)
+	}
+	if s.Mode == ctype.ModeCoop && s.Rounds != 1 {
+		return fmt.Errorf("合作模式只能进行一局")
+	}
+	if s.TimeLimit != 0 && (s.TimeLimit < MinTimeLimit || s.TimeLimit > MaxTimeLimit) {
+		return fmt.Errorf("时间上限需为 %d~%d 秒，0 表示不限", MinTimeLimit, MaxTimeLimit)
+	}
+	if s.TickRate < 0 || s.SendRate < 0 {
+		return fmt.Errorf("频率不能为负数")
+	}
+	if _, _, err := resolveRates(s.TickRate, s.SendRate); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ApplySettings 把大厅房间的设置带入对局房间，需在 InitRoom 之前调用。
+// 频率在创建房间时已校验，配置的上限之后被调低时返回错误
+func ApplySettings(room *model.Room, s model.RoomSettings) error {
+	room.Mode = s.Mode
+	room.Arena = s.Arena
+	room.Rounds = s.Rounds
+	room.FriendlyFire = s.FriendlyFire
+	room.TimeLimit = time.Duration(s.TimeLimit) * time.Second
+	return SetRates(room, s.TickRate, s.SendRate)
+}
+
+// roundTimeUp 本局是否已到达时间上限
+func roundTimeUp(room *model.Room, now time.Time) bool {
+	return room.TimeLimit > 0 && !room.RoundEnd.IsZero() && !now.Before(room.RoundEnd)
+}
+
+// hpLeader 血量比例最高的玩家，并列时没有胜者
+func hpLeader(players []*model.Player) *model.Player {
+	var leader *model.Player
+	best := -1.0
+	for _, p := range players {
+		ratio := float64(p.HP) / float64(max(p.MaxHP, 1))
+		switch {
+		case ratio > best:
+			leader, best = p, ratio
+		case math.Abs(ratio-best) < 1e-9:
+			leader = nil
+		}
+	}
+	return leader
+}
+
+// endRound 记录本局的胜者，还有后续局时重置场地并重新倒计时，返回 true 表示对局继续
+func endRound(room *model.Room, winner *model.Player, now time.Time) bool {
+	if winner != nil {
+		room.RoundWins[winner.ID]++
+	}
+	if room.Round >= room.Rounds || (winner != nil && room.RoundWins[winner.ID] > room.Rounds/2) {
+		return false
+	}
+	broadcast(room, map[string]interface{}{
+		"type":   "round_over",
+		"round":  room.Round,
+		"winner": winner,
+		"wins":   room.RoundWins,
+	})
+	room.Round++
+	resetRound(room, now)
+	return true
+}
+
+// matchWinner 赢下局数最多的玩家，并列时为和局
+func matchWinner(room *model.Room) *model.Player {
+	var winner *model.Player
+	best := 0
+	for _, p := range room.Players {
+		wins := room.RoundWins[p.ID]
+		switch {
+		case wins > best:
+			winner, best = p, wins
+		case wins == best && wins > 0:
+			winner = nil
+		}
+	}
+	return winner
+}
+
+// resetRound 清空场地并让玩家回到出生点，重新倒计时开始下一局
+func resetRound(room *model.Room, now time.Time) {
+	room.Bullets = []*model.Bullet{}
+	room.PowerUps = nil
+	room.NextDrop = time.Time{}
+	room.RoundEnd = time.Time{}
+	room.History = nil
+	room.Vote = nil
+	initObstacles(room)
+	spawnPlayers(room)
+	if !setStatus(room, ctype.GameCountdown) {
+		return
+	}
+	room.StartAt = now.Add(CountdownDuration)
+	room.Countdown = 0
+	updateCountdown(room, now)
+}
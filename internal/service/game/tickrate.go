@@ -0,0 +1,94 @@
+package game
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"math"
+	"plane_war/internal/model"
+	"time"
+)
+
+// BaseTickRate 配置中所有“每 tick”数值（移动速度、子弹速度与寿命、转向角、敌机路径）所基于的频率，
+// 房间以其他频率模拟时按比例换算
+const BaseTickRate = 20
+
+// RateConfig 房间的模拟与快照发送频率，由 internal/etc/room.yaml 定义
+type RateConfig struct {
+	TickRate    int `mapstructure:"tick_rate"`     // 默认模拟频率（Hz）
+	SendRate    int `mapstructure:"send_rate"`     // 默认快照发送频率（Hz），不超过模拟频率
+	MaxTickRate int `mapstructure:"max_tick_rate"` // 房间可设置的最高模拟频率
+}
+
+// Rates 房间频率配置
+var Rates = RateConfig{TickRate: BaseTickRate, SendRate: BaseTickRate, MaxTickRate: BaseTickRate}
+
+// LoadRates 从配置文件加载房间频率配置
+func LoadRates(file string) error {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("读取房间配置失败: %v", err)
+	}
+	var cfg RateConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return fmt.Errorf("解析房间配置失败: %v", err)
+	}
+	if cfg.MaxTickRate <= 0 {
+		return fmt.Errorf("max_tick_rate 必须大于 0")
+	}
+	if err := validRates(cfg.TickRate, cfg.SendRate, cfg.MaxTickRate); err != nil {
+		return err
+	}
+	Rates = cfg
+	return nil
+}
+
+func validRates(tickRate, sendRate, maxTickRate int) error {
+	if tickRate <= 0 || tickRate > maxTickRate {
+		return fmt.Errorf("模拟频率必须在 1~%d 之间", maxTickRate)
+	}
+	if sendRate <= 0 || sendRate > tickRate {
+		return fmt.Errorf("发送频率必须在 1~%d 之间", tickRate)
+	}
+	return nil
+}
+
+// SetRates 设置房间的模拟与发送频率，0 表示使用默认值，需在 StartRoomLoop 之前调用
+func SetRates(room *model.Room, tickRate, sendRate int) error {
+	tickRate, sendRate, err := resolveRates(tickRate, sendRate)
+	if err != nil {
+		return err
+	}
+	room.TickRate = tickRate
+	room.SendRate = sendRate
+	return nil
+}
+
+// resolveRates 用默认值补全为 0 的频率并校验
+func resolveRates(tickRate, sendRate int) (int, int, error) {
+	if tickRate == 0 {
+		tickRate = Rates.TickRate
+	}
+	if sendRate == 0 {
+		sendRate = min(Rates.SendRate, tickRate)
+	}
+	if err := validRates(tickRate, sendRate, Rates.MaxTickRate); err != nil {
+		return 0, 0, err
+	}
+	return tickRate, sendRate, nil
+}
+
+// tickInterval 房间每个 tick 的时长
+func tickInterval(room *model.Room) time.Duration {
+	return time.Second / time.Duration(room.TickRate)
+}
+
+// tickScale 房间一个 tick 相当于多少个基准 tick
+func tickScale(room *model.Room) float64 {
+	return float64(BaseTickRate) / float64(room.TickRate)
+}
+
+// sendEvery 每隔多少个 tick 发送一次快照
+func sendEvery(room *model.Room) int {
+	return max(1, int(math.Round(float64(room.TickRate)/float64(room.SendRate))))
+}
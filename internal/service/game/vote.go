@@ -0,0 +1,200 @@
+package game
+
+import (
+	"fmt"
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"slices"
+	"time"
+)
+
+// 对局投票类型
+const (
+	VotePause = "pause" // 暂停
+	VoteDraw  = "draw"  // 和局
+)
+
+// 对局提前结束的原因
+const (
+	EndSurrender = "surrender"
+	EndDraw      = "draw"
+)
+
+const (
+	// PauseBudget 每名玩家每局可用的暂停时长
+	PauseBudget = 60 * time.Second
+	// maxPause 单次暂停的最长时间
+	maxPause = 30 * time.Second
+	// voteTimeout 发起投票后等待其他玩家同意的时间
+	voteTimeout = 10 * time.Second
+)
+
+// RequestMatchAction 处理投降、暂停、恢复与求和请求，结果由游戏循环结算，调用方需持有 room.Lock
+func RequestMatchAction(room *model.Room, p *model.Player, action string) error {
+	switch action {
+	case "surrender":
+		if p.Surrender {
+			return fmt.Errorf("已经投降")
+		}
+		p.Surrender = true
+		return nil
+	case "pause":
+		return requestVote(room, p, VotePause)
+	case "draw":
+		return requestVote(room, p, VoteDraw)
+	case "resume":
+		if room.Status != ctype.GamePaused {
+			return fmt.Errorf("对局没有暂停")
+		}
+		room.PauseEnd = time.Now()
+		return nil
+	}
+	return fmt.Errorf("未知的操作 %s", action)
+}
+
+// requestVote 发起投票或对进行中的同类投票表示同意
+func requestVote(room *model.Room, p *model.Player, kind string) error {
+	if room.Status != ctype.GameRunning && room.Status != ctype.GamePaused {
+		return fmt.Errorf("对局尚未开始")
+	}
+	switch kind {
+	case VotePause:
+		if room.Status == ctype.GamePaused {
+			return fmt.Errorf("对局已经暂停")
+		}
+	case VoteDraw:
+		if room.Mode == ctype.ModeCoop {
+			return fmt.Errorf("合作模式不能求和")
+		}
+	}
+	if v := room.Vote; v != nil {
+		if v.Kind != kind {
+			return fmt.Errorf("已有进行中的 %s 投票", v.Kind)
+		}
+		if !slices.Contains(v.Voters, p.ID) {
+			v.Voters = append(v.Voters, p.ID)
+		}
+		return nil
+	}
+	if kind == VotePause && p.PauseLeft <= 0 {
+		return fmt.Errorf("暂停时间已用完")
+	}
+	room.Vote = &model.Vote{
+		Kind:      kind,
+		From:      p.ID,
+		Voters:    []string{p.ID},
+		ExpiresAt: time.Now().Add(voteTimeout).UnixMilli(),
+	}
+	return nil
+}
+
+// updateVotes 结算投降、投票与暂停超时，在每个 tick 的模拟之前执行。
+// 投降的玩家视为本局被击败，之后的每一局也不再参与，是否提前结束整场对局由 checkGameOver 判断
+func updateVotes(room *model.Room, now time.Time) {
+	for _, p := range room.Players {
+		if p.Surrender && p.HP > 0 {
+			p.HP = 0
+		}
+	}
+	if v := room.Vote; v != nil {
+		if len(v.Voters) >= len(room.Players) {
+			room.Vote = nil
+			passVote(room, v, now)
+		} else if now.UnixMilli() >= v.ExpiresAt {
+			room.Vote = nil
+			broadcast(room, map[string]interface{}{
+				"type": "vote_failed",
+				"kind": v.Kind,
+			})
+		}
+	}
+	if room.Status == ctype.GamePaused && !now.Before(room.PauseEnd) {
+		resume(room, now)
+	}
+}
+
+// standing 尚未投降的玩家
+func standing(room *model.Room) []*model.Player {
+	var players []*model.Player
+	for _, p := range room.Players {
+		if !p.Surrender {
+			players = append(players, p)
+		}
+	}
+	return players
+}
+
+func passVote(room *model.Room, v *model.Vote, now time.Time) {
+	switch v.Kind {
+	case VotePause:
+		var left int64
+		for _, p := range room.Players {
+			if p.ID == v.From {
+				left = p.PauseLeft
+			}
+		}
+		d := min(time.Duration(left)*time.Millisecond, maxPause)
+		if d <= 0 {
+			return
+		}
+		setStatus(room, ctype.GamePaused)
+		room.PausedBy = v.From
+		room.PausedAt = now
+		room.PauseEnd = now.Add(d)
+		broadcast(room, map[string]interface{}{
+			"type":  "paused",
+			"by":    v.From,
+			"until": room.PauseEnd.UnixMilli(),
+		})
+	case VoteDraw:
+		room.EndReason = EndDraw
+	}
+}
+
+// resume 恢复对局，暂停时长从发起者的额度中扣除，并顺延所有计时器
+func resume(room *model.Room, now time.Time) {
+	elapsed := now.Sub(room.PausedAt)
+	for _, p := range room.Players {
+		if p.ID == room.PausedBy {
+			p.PauseLeft = max(p.PauseLeft-elapsed.Milliseconds(), 0)
+		}
+	}
+	shiftTimers(room, elapsed)
+	setStatus(room, ctype.GameRunning)
+	room.PausedBy = ""
+	broadcast(room, map[string]interface{}{"type": "resumed"})
+}
+
+// shiftTimers 将基于时间的状态整体顺延 d，暂停期间冷却、道具与波次都不会推进
+func shiftTimers(room *model.Room, d time.Duration) {
+	ms := d.Milliseconds()
+	if !room.RoundEnd.IsZero() {
+		room.RoundEnd = room.RoundEnd.Add(d)
+	}
+	if !room.NextDrop.IsZero() {
+		room.NextDrop = room.NextDrop.Add(d)
+	}
+	for _, pu := range room.PowerUps {
+		pu.ExpiresAt += ms
+	}
+	for _, p := range room.Players {
+		if st := p.Weapon; st != nil {
+			st.LastFireAt = st.LastFireAt.Add(d)
+			st.ReloadDoneAt = st.ReloadDoneAt.Add(d)
+		}
+		for _, e := range p.Effects {
+			e.ExpiresAt += ms
+		}
+		for _, a := range p.Abilities {
+			if a.ReadyAt > 0 {
+				a.ReadyAt += ms
+			}
+		}
+	}
+	if room.PvE != nil && !room.PvE.WaveStart.IsZero() {
+		room.PvE.WaveStart = room.PvE.WaveStart.Add(d)
+	}
+	for _, e := range room.Enemies {
+		e.NextFire = e.NextFire.Add(d)
+	}
+}
@@ -0,0 +1,226 @@
+package game
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"log"
+	"math"
+	"plane_war/internal/model"
+	"time"
+)
+
+// Weapon 武器参数，由 internal/etc/weapons.yaml 定义
+type Weapon struct {
+	Name         string        `mapstructure:"name"`
+	Damage       int           `mapstructure:"damage"`        // 单发伤害
+	Speed        float64       `mapstructure:"speed"`         // 子弹每 tick 移动距离
+	Spread       float64       `mapstructure:"spread"`        // 多发子弹的总散布角度（度）
+	Projectiles  int           `mapstructure:"projectiles"`   // 每次开火的子弹数
+	Piercing     int           `mapstructure:"piercing"`      // 可穿透的目标数
+	Homing       float64       `mapstructure:"homing"`        // 每 tick 最大转向角度（度），0 表示不追踪
+	Lifetime     int           `mapstructure:"lifetime"`      // 子弹存活 tick 数，0 表示直到飞出场地
+	Width        float64       `mapstructure:"width"`         // 子弹宽度
+	Height       float64       `mapstructure:"height"`        // 子弹高度
+	Shape        string        `mapstructure:"shape"`         // 子弹碰撞形状：aabb（默认）/circle
+	FireInterval time.Duration `mapstructure:"fire_interval"` // 两次开火的最小间隔
+	MagazineSize int           `mapstructure:"magazine_size"` // 弹匣容量
+	ReloadTime   time.Duration `mapstructure:"reload_time"`   // 换弹耗时
+}
+
+const (
+	maxPendingShots  = 10 // 两个 tick 之间最多缓存的开火请求，多余的直接拒绝
+	cheatRejectLimit = 50 // 被拒绝的开火次数达到该值时记录疑似作弊
+)
+
+var (
+	// Weapons 武器列表
+	Weapons = map[string]Weapon{}
+	// DefaultWeapon 未选择或选择了不存在的武器时使用
+	DefaultWeapon string
+)
+
+// LoadWeapons 从配置文件加载武器定义
+func LoadWeapons(file string) error {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("读取武器配置失败: %v", err)
+	}
+	var list []Weapon
+	if err := v.UnmarshalKey("weapons", &list); err != nil {
+		return fmt.Errorf("解析武器配置失败: %v", err)
+	}
+
+	weapons := make(map[string]Weapon, len(list))
+	for _, w := range list {
+		if w.Name == "" {
+			return fmt.Errorf("武器缺少 name")
+		}
+		if w.Projectiles < 1 {
+			w.Projectiles = 1
+		}
+		if w.MagazineSize < 1 {
+			return fmt.Errorf("武器 %s 的 magazine_size 必须大于 0", w.Name)
+		}
+		if w.FireInterval <= 0 {
+			return fmt.Errorf("武器 %s 的 fire_interval 必须大于 0", w.Name)
+		}
+		// 子弹没有顶点定义，只支持碰撞盒与圆形
+		if err := validHitbox(Hitbox{Shape: w.Shape}); err != nil || w.Shape == ShapePolygon {
+			return fmt.Errorf("武器 %s 的 shape 只能是 %s 或 %s", w.Name, ShapeAABB, ShapeCircle)
+		}
+		weapons[w.Name] = w
+	}
+	def := v.GetString("default")
+	if _, ok := weapons[def]; !ok {
+		return fmt.Errorf("默认武器 %s 不存在", def)
+	}
+
+	Weapons = weapons
+	DefaultWeapon = def
+	return nil
+}
+
+// NewWeaponState 按武器定义生成满弹匣的武器状态
+func NewWeaponState(name string) *model.WeaponState {
+	w, ok := Weapons[name]
+	if !ok {
+		w = Weapons[DefaultWeapon]
+	}
+	return &model.WeaponState{
+		Name:         w.Name,
+		Ammo:         w.MagazineSize,
+		MagazineSize: w.MagazineSize,
+	}
+}
+
+// RequestShoot 记录玩家的开火请求，是否真正开火由游戏循环判定，调用方需持有 room.Lock。
+// renderTick 为客户端开火时画面上的 tick，用于延迟补偿，0 表示不回溯
+func RequestShoot(p *model.Player, renderTick uint64) {
+	if p.Weapon == nil {
+		return
+	}
+	if p.Weapon.PendingShots >= maxPendingShots {
+		rejectShot(p)
+		return
+	}
+	p.Weapon.PendingShots++
+	p.Weapon.RenderTick = renderTick
+}
+
+// RequestReload 玩家主动换弹，调用方需持有 room.Lock
+func RequestReload(p *model.Player, now time.Time) {
+	st := p.Weapon
+	if st == nil || st.Reloading || st.Ammo >= st.MagazineSize {
+		return
+	}
+	startReload(st, now)
+}
+
+// fireWeapons 处理所有玩家的开火请求，每个 tick 每名玩家至多开火一次
+func fireWeapons(room *model.Room, now time.Time) {
+	for _, p := range room.Players {
+		st := p.Weapon
+		if st == nil {
+			continue
+		}
+		w := Weapons[st.Name]
+		if st.Reloading && !now.Before(st.ReloadDoneAt) {
+			st.Reloading = false
+			st.Ammo = st.MagazineSize
+		}
+		pending := st.PendingShots
+		st.PendingShots = 0
+		if pending == 0 {
+			continue
+		}
+		if p.HP <= 0 || st.Reloading || st.Ammo <= 0 || now.Sub(st.LastFireAt) < w.FireInterval {
+			for i := 0; i < pending; i++ {
+				rejectShot(p)
+			}
+			continue
+		}
+
+		if hasEffect(p, PowerUpTripleShot) {
+			w = tripleShot(w)
+		}
+		bullets := spawnBullets(p, w)
+		rewind := rewindTicks(room, p, st.RenderTick)
+		for _, b := range bullets {
+			b.Rewind = rewind
+			// 寿命按基准 tick 配置，换算为房间的 tick 数
+			b.Life = int(math.Ceil(float64(b.Life) / tickScale(room)))
+		}
+		room.Bullets = append(room.Bullets, bullets...)
+
+		st.LastFireAt = now
+		st.Ammo--
+		if st.Ammo == 0 {
+			startReload(st, now)
+		}
+		// 同一 tick 内多余的请求视为超出射速
+		for i := 1; i < pending; i++ {
+			rejectShot(p)
+		}
+	}
+}
+
+// spawnBullets 按武器的弹道参数生成一次开火的全部子弹，多发子弹在散布角内均匀分布
+func spawnBullets(p *model.Player, w Weapon) []*model.Bullet {
+	dir := -1.0 //向上
+	if p.Position == "top" {
+		dir = 1 //向下
+	}
+	// 子弹从飞机中心射出
+	x := float64(p.X) + float64(p.Width)/2 - w.Width/2
+	y := float64(p.Y)
+
+	bullets := make([]*model.Bullet, 0, w.Projectiles)
+	for i := 0; i < w.Projectiles; i++ {
+		angle := 0.0
+		if w.Projectiles > 1 {
+			angle = -w.Spread/2 + w.Spread*float64(i)/float64(w.Projectiles-1)
+		}
+		rad := angle * math.Pi / 180
+		bullets = append(bullets, &model.Bullet{
+			ID:     uuid.New().String(),
+			Weapon: w.Name,
+			X:      x,
+			Y:      y,
+			VX:     w.Speed * math.Sin(rad),
+			VY:     dir * w.Speed * math.Cos(rad),
+			Width:  w.Width,
+			Height: w.Height,
+			Shape:  w.Shape,
+			Owner:  p.ID,
+			Damage: w.Damage,
+			Pierce: w.Piercing,
+			Homing: w.Homing,
+			Life:   w.Lifetime,
+		})
+	}
+	return bullets
+}
+
+// tripleShot 三倍弹幕：子弹数量翻三倍，单发武器额外获得散布角
+func tripleShot(w Weapon) Weapon {
+	w.Projectiles *= 3
+	if w.Spread == 0 {
+		w.Spread = 30
+	}
+	return w
+}
+
+func startReload(st *model.WeaponState, now time.Time) {
+	st.Reloading = true
+	st.ReloadDoneAt = now.Add(Weapons[st.Name].ReloadTime)
+}
+
+// rejectShot 统计被拒绝的开火请求
+func rejectShot(p *model.Player) {
+	p.Weapon.RejectedShots++
+	if p.Weapon.RejectedShots == cheatRejectLimit {
+		log.Printf("玩家 %s(%s) 开火请求被拒绝 %d 次，疑似使用脚本", p.Name, p.ID, cheatRejectLimit)
+	}
+}
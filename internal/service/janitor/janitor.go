@@ -0,0 +1,111 @@
+package janitor
+
+import (
+	"plane_war/internal/global"
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"plane_war/internal/service/game"
+	"plane_war/internal/service/redis_service"
+	"plane_war/internal/ws"
+	"time"
+)
+
+const (
+	// Interval 清理大厅房间的间隔
+	Interval = 30 * time.Second
+	// OfflineGrace 房间成员全部离线超过该时间后关闭房间，给断线重连留出时间
+	OfflineGrace = 2 * time.Minute
+	// StartGrace 占住开局后创建对局房间的时间，期间没有对局房间不算对局已结束
+	StartGrace = 30 * time.Second
+)
+
+// janitor 定期清理大厅：房间列表中已过期的房间码、成员全部离线的房间、对局已结束却仍是游戏中的房间
+type janitor struct {
+	offlineSince map[string]time.Time //房间码到首次发现成员全部离线的时间
+}
+
+// Start 启动大厅清理协程
+func Start() {
+	j := &janitor{offlineSince: make(map[string]time.Time)}
+	go func() {
+		ticker := time.NewTicker(Interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			j.sweep(now)
+		}
+	}()
+}
+
+func (j *janitor) sweep(now time.Time) {
+	if n, err := redis_service.CleanRoomList(); err != nil {
+		global.Log.Warnf("清理房间列表失败: %v", err)
+	} else if n > 0 {
+		global.Log.Infof("清理了 %d 个已过期的房间码", n)
+	}
+
+	rooms, err := redis_service.AllPublicRooms()
+	if err != nil {
+		global.Log.Warnf("扫描大厅房间失败: %v", err)
+		return
+	}
+	seen := make(map[string]bool, len(rooms))
+	for _, room := range rooms {
+		seen[room.Code] = true
+		playing := game.GetRoom(room.ID) != nil
+		starting := now.Sub(time.UnixMilli(room.StartedAt)) < StartGrace
+		if room.Status == ctype.Playing && !playing && !starting {
+			j.resetPlaying(room)
+		}
+		if playing || anyOnline(room) {
+			delete(j.offlineSince, room.Code)
+			continue
+		}
+		since, ok := j.offlineSince[room.Code]
+		if !ok {
+			j.offlineSince[room.Code] = now
+			continue
+		}
+		if now.Sub(since) >= OfflineGrace {
+			j.close(room)
+			delete(j.offlineSince, room.Code)
+		}
+	}
+	// 已经不存在的房间不再跟踪
+	for code := range j.offlineSince {
+		if !seen[code] {
+			delete(j.offlineSince, code)
+		}
+	}
+}
+
+// resetPlaying 对局已归档但大厅房间仍是游戏中（例如服务重启），恢复为等待中
+func (j *janitor) resetPlaying(room *model.PublicRoom) {
+	updated, err := redis_service.SetPublicRoomStatus(room.Code, ctype.Waiting)
+	if err != nil {
+		global.Log.Warnf("房间 %s 恢复等待失败: %v", room.Code, err)
+		return
+	}
+	global.Log.Infof("房间 %s 的对局已结束，恢复为等待中", room.Code)
+	ws.PublishLobbyEvent(updated, ws.GameEnded, updated.OwnerID)
+}
+
+// close 关闭成员全部离线的房间
+func (j *janitor) close(room *model.PublicRoom) {
+	closed, err := redis_service.ClosePublicRoom(room.Code)
+	if err != nil {
+		global.Log.Warnf("关闭房间 %s 失败: %v", room.Code, err)
+		return
+	}
+	global.Log.Infof("房间 %s 的成员已全部离线，关闭房间", room.Code)
+	ws.PublishLobbyEvent(closed, ws.Dismissed, 0)
+}
+
+// anyOnline 房间内是否有成员保持着 WebSocket 连接
+func anyOnline(room *model.PublicRoom) bool {
+	for _, p := range room.Players {
+		if ws.FindClientByUserID(p.UserID) != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -1,37 +1,162 @@
 package match
 
 import (
+	"fmt"
 	"github.com/google/uuid"
 	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
 	"sync"
+	"time"
 )
 
-// MatchQueue 匹配队列
+const (
+	// maxPingGap 同一房间玩家之间允许的最大延迟差（毫秒）
+	maxPingGap = 60
+	// pingGapGrowth 排队每满一秒放宽的延迟差（毫秒），避免高延迟玩家一直匹配不到
+	pingGapGrowth = 20
+)
+
+// entry 排队中的玩家及其入队时的延迟
+type entry struct {
+	player *model.Player
+	ping   int // 往返延迟（毫秒），0 表示未知
+	joined time.Time
+}
+
+// tolerance 该玩家当前能接受的延迟差
+func (e entry) tolerance(now time.Time) int {
+	return maxPingGap + int(now.Sub(e.joined)/time.Second)*pingGapGrowth
+}
+
+// queue 同一模式、人数、场地的排队玩家，按入队顺序排列
+type queue struct {
+	mode    ctype.GameMode
+	size    int
+	arena   string
+	entries []entry
+}
+
+// MatchQueue 匹配队列，不同模式、人数、场地的对局分别排队，同一队列内优先凑延迟接近的玩家
 type MatchQueue struct {
-	queue []*model.Player
-	lock  sync.Mutex
+	queues map[string]*queue
+	lock   sync.Mutex
 }
 
 var MatchQueueInstance = &MatchQueue{
-	queue: make([]*model.Player, 0),
+	queues: make(map[string]*queue),
 }
 
+// AddPlayer 加入双人对战匹配，使用默认场地
 func (mq *MatchQueue) AddPlayer(p *model.Player) *model.Room {
+	return mq.add(ctype.ModePvP, 2, "", p, 0)
+}
+
+// AddPvPPlayer 加入指定场地的双人对战匹配，ping 为玩家当前延迟
+func (mq *MatchQueue) AddPvPPlayer(p *model.Player, arena string, ping int) *model.Room {
+	return mq.add(ctype.ModePvP, 2, arena, p, ping)
+}
+
+// AddCoopPlayer 加入合作模式匹配，size 为队伍人数（1~4），凑满即开局
+func (mq *MatchQueue) AddCoopPlayer(p *model.Player, size int, arena string, ping int) *model.Room {
+	return mq.add(ctype.ModeCoop, size, arena, p, ping)
+}
+
+// add 玩家入队并尝试凑一局，凑成的房间不一定包含新玩家（排队较久的玩家放宽了延迟差）
+func (mq *MatchQueue) add(mode ctype.GameMode, size int, arena string, p *model.Player, ping int) *model.Room {
+	mq.lock.Lock()
+	defer mq.lock.Unlock()
+
+	now := time.Now()
+	key := fmt.Sprintf("%s:%d:%s", mode, size, arena)
+	q := mq.queues[key]
+	if q == nil {
+		q = &queue{mode: mode, size: size, arena: arena}
+		mq.queues[key] = q
+	}
+	q.entries = append(q.entries, entry{player: p, ping: ping, joined: now})
+	return q.take(now)
+}
+
+// Rescan 重新检查所有队列，排队时间变长后放宽的延迟差可能已能凑成对局，返回新凑成的房间
+func (mq *MatchQueue) Rescan(now time.Time) []*model.Room {
 	mq.lock.Lock()
 	defer mq.lock.Unlock()
 
-	mq.queue = append(mq.queue, p)
+	var rooms []*model.Room
+	for key, q := range mq.queues {
+		for room := q.take(now); room != nil; room = q.take(now) {
+			rooms = append(rooms, room)
+		}
+		if len(q.entries) == 0 {
+			delete(mq.queues, key)
+		}
+	}
+	return rooms
+}
+
+// take 按入队顺序凑一组两两延迟相容的玩家，人数够了就移出队列并创建房间
+func (q *queue) take(now time.Time) *model.Room {
+	for i := range q.entries {
+		picked := q.pick([]int{i}, i+1, now)
+		if picked == nil {
+			continue
+		}
 
-	//如果队列 >=2 就创建房间
-	if len(mq.queue) >= 2 {
-		p1 := mq.queue[0]
-		p2 := mq.queue[1]
-		mq.queue = mq.queue[2:]
-		room := &model.Room{
+		players := make([]*model.Player, 0, q.size)
+		remain := make([]entry, 0, len(q.entries)-q.size)
+		for k, e := range q.entries {
+			if len(picked) > 0 && picked[0] == k {
+				players = append(players, e.player)
+				picked = picked[1:]
+				continue
+			}
+			remain = append(remain, e)
+		}
+		q.entries = remain
+		return &model.Room{
 			ID:      uuid.New().String(),
-			Players: []*model.Player{p1, p2},
+			Mode:    q.mode,
+			Arena:   q.arena,
+			Players: players,
 		}
-		return room
 	}
 	return nil
 }
+
+// pick 从 from 开始回溯挑选与已选玩家两两相容的玩家，凑不满人数时返回 nil
+func (q *queue) pick(picked []int, from int, now time.Time) []int {
+	if len(picked) == q.size {
+		return picked
+	}
+	for j := from; j < len(q.entries); j++ {
+		if !compatibleWith(q.entries, picked, q.entries[j], now) {
+			continue
+		}
+		if res := q.pick(append(picked, j), j+1, now); res != nil {
+			return res
+		}
+	}
+	return nil
+}
+
+// compatibleWith 玩家是否与已选中的每名玩家都能同房
+func compatibleWith(entries []entry, picked []int, e entry, now time.Time) bool {
+	for _, i := range picked {
+		if !compatible(entries[i], e, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// compatible 两名玩家能否同房：任一方延迟未知，或延迟差在排队较久一方的容忍范围内
+func compatible(a, b entry, now time.Time) bool {
+	if a.ping == 0 || b.ping == 0 {
+		return true
+	}
+	gap := a.ping - b.ping
+	if gap < 0 {
+		gap = -gap
+	}
+	return gap <= max(a.tolerance(now), b.tolerance(now))
+}
@@ -0,0 +1,53 @@
+package match
+
+import (
+	"plane_war/internal/model"
+	"testing"
+	"time"
+)
+
+func newQueue() *MatchQueue {
+	return &MatchQueue{queues: make(map[string]*queue)}
+}
+
+func TestRescanWidensTolerance(t *testing.T) {
+	mq := newQueue()
+	if room := mq.AddPvPPlayer(&model.Player{ID: "a"}, "", 20); room != nil {
+		t.Fatal("单人不应开局")
+	}
+	if room := mq.AddPvPPlayer(&model.Player{ID: "b"}, "", 150); room != nil {
+		t.Fatal("延迟差 130ms 不应立即开局")
+	}
+	if rooms := mq.Rescan(time.Now()); len(rooms) != 0 {
+		t.Fatalf("刚入队时不应开局，得到 %d 个房间", len(rooms))
+	}
+	// 排队 4 秒后容忍 60+4*20=140ms
+	rooms := mq.Rescan(time.Now().Add(4 * time.Second))
+	if len(rooms) != 1 || len(rooms[0].Players) != 2 {
+		t.Fatalf("放宽后应凑成一局，得到 %v", rooms)
+	}
+	if len(mq.queues) != 0 {
+		t.Fatalf("开局后队列应为空，剩余 %d 个队列", len(mq.queues))
+	}
+}
+
+func TestCoopGroupPairwise(t *testing.T) {
+	mq := newQueue()
+	// b、c 都与 a 相容，但 b、c 之间延迟差 120ms
+	mq.AddCoopPlayer(&model.Player{ID: "a"}, 3, "", 100)
+	mq.AddCoopPlayer(&model.Player{ID: "b"}, 3, "", 40)
+	if room := mq.AddCoopPlayer(&model.Player{ID: "c"}, 3, "", 160); room != nil {
+		t.Fatalf("b、c 不相容，不应开局: %v", room.Players)
+	}
+	room := mq.AddCoopPlayer(&model.Player{ID: "d"}, 3, "", 110)
+	if room == nil {
+		t.Fatal("a、c、d 两两相容，应开局")
+	}
+	ids := map[string]bool{}
+	for _, p := range room.Players {
+		ids[p.ID] = true
+	}
+	if ids["b"] || len(ids) != 3 {
+		t.Fatalf("房间成员应为 a、c、d，得到 %v", ids)
+	}
+}
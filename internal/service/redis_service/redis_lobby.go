@@ -5,54 +5,150 @@ import (
 	"fmt"
 	"plane_war/internal/global"
 	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"plane_war/internal/utils/pwd"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis"
 )
 
-// SavePublicRoomToRedis 保存公共房间到 Redis
-func SavePublicRoomToRedis(room *model.PublicRoom, userID uint) error {
-	// 检查用户是否已有房间
-	existingRoomCode, _ := global.Redis.Get(fmt.Sprintf("userRoom:%d", userID)).Result()
-	if existingRoomCode != "" {
-		// 删除之前的房间
-		err := DeletePublicRoom(existingRoomCode)
-		if err != nil {
+const (
+	// roomTTL 房间及用户绑定的过期时间
+	roomTTL = 24 * time.Hour
+	// maxTxRetries 乐观锁冲突时的最大重试次数
+	maxTxRetries = 20
+	// listBatch 读取房间列表时每个 pipeline 读取的房间数
+	listBatch = 100
+	// AutoStartDelay 开启自动开始的房间全员准备后到开始游戏的倒计时
+	AutoStartDelay = 5 * time.Second
+)
+
+func roomKey(code string) string {
+	return "publicRoom:" + code
+}
+
+// roomPasswordKey 密码房间的密码哈希，与房间详情分开保存，避免随房间信息下发
+func roomPasswordKey(code string) string {
+	return "publicRoomPwd:" + code
+}
+
+func userRoomKey(userID uint) string {
+	return fmt.Sprintf("userRoom:%d", userID)
+}
+
+// errRoomNotFound 房间不存在
+var errRoomNotFound = fmt.Errorf("房间不存在")
+
+// updatePublicRoom 在 WATCH 事务中读取房间，由 fn 校验并修改后写回，房间被其他请求抢先修改时重试。
+// fn 可以向 pipe 追加同一事务内的其他命令；修改后房间没有玩家时直接删除房间。
+// 返回写回后的房间，用于推送大厅事件
+func updatePublicRoom(code string, fn func(room *model.PublicRoom, pipe redis.Pipeliner) error) (*model.PublicRoom, error) {
+	return watchPublicRoom(code, nil, func(_ *redis.Tx, room *model.PublicRoom, pipe redis.Pipeliner) error {
+		return fn(room, pipe)
+	})
+}
+
+// watchPublicRoom 与 updatePublicRoom 相同，额外 WATCH keys，fn 可以通过 tx 读取这些键，它们被修改时事务同样重试
+func watchPublicRoom(code string, keys []string, fn func(tx *redis.Tx, room *model.PublicRoom, pipe redis.Pipeliner) error) (*model.PublicRoom, error) {
+	key := roomKey(code)
+	var result *model.PublicRoom
+	for i := 0; i < maxTxRetries; i++ {
+		err := global.Redis.Watch(func(tx *redis.Tx) error {
+			data, err := tx.Get(key).Result()
+			if err == redis.Nil {
+				return errRoomNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("获取房间信息失败: %v", err)
+			}
+			var room model.PublicRoom
+			if err := json.Unmarshal([]byte(data), &room); err != nil {
+				return fmt.Errorf("解析房间数据失败: %v", err)
+			}
+			_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+				if err := fn(tx, &room, pipe); err != nil {
+					return err
+				}
+				if len(room.Players) == 0 {
+					pipe.ZRem("publicRooms", code)
+					pipe.Del(key, roomPasswordKey(code))
+					return nil
+				}
+				roomData, _ := json.Marshal(&room)
+				pipe.Set(key, roomData, roomTTL)
+				pipe.Expire(roomPasswordKey(code), roomTTL)
+				return nil
+			})
+			if err == nil {
+				result = &room
+			}
 			return err
+		}, append([]string{key}, keys...)...)
+		if err != redis.TxFailedErr {
+			return result, err
 		}
 	}
+	return nil, fmt.Errorf("房间繁忙，请稍后重试")
+}
 
-	// 保存房间码到 ZSet
-	score := float64(room.Created.Unix())
-	if err := global.Redis.ZAdd("publicRooms", redis.Z{
-		Score:  score,
-		Member: room.Code,
-	}).Err(); err != nil {
-		return fmt.Errorf("保存房间失败: %v", err)
+// SavePublicRoomToRedis 为房间分配房间码并保存到 Redis，passwordHash 非空时房间需要密码加入
+func SavePublicRoomToRedis(room *model.PublicRoom, userID uint, passwordHash string) error {
+	// 检查用户是否已有房间，有则先离开（房主离开时转让房主）
+	existingRoomCode, _ := global.Redis.Get(userRoomKey(userID)).Result()
+	if existingRoomCode != "" {
+		_, err := RemovePlayerFromRoom(existingRoomCode, userID)
+		if err != nil && err != errRoomNotFound {
+			return err
+		}
 	}
 
-	// 保存房间详细信息
-	roomKey := "publicRoom:" + room.Code
-	roomData, _ := json.Marshal(room)
-	if err := global.Redis.Set(roomKey, roomData, 24*time.Hour).Err(); err != nil {
-		return fmt.Errorf("保存房间详细信息失败: %v", err)
+	// 用 SETNX 写入房间详情来预留房间码，房间删除或过期后房间码才会被重新分配
+	reserved := false
+	for i := 0; i < maxCodeRetries && !reserved; i++ {
+		room.Code = generateRoomCode()
+		roomData, _ := json.Marshal(room)
+		ok, err := global.Redis.SetNX(roomKey(room.Code), roomData, roomTTL).Result()
+		if err != nil {
+			return fmt.Errorf("保存房间失败: %v", err)
+		}
+		reserved = ok
 	}
-
-	// 绑定用户与房间
-	if err := global.Redis.Set(fmt.Sprintf("userRoom:%d", userID), room.Code, 24*time.Hour).Err(); err != nil {
-		return fmt.Errorf("绑定用户房间失败: %v", err)
+	if !reserved {
+		return fmt.Errorf("分配房间码失败，请稍后重试")
 	}
 
+	// 房间列表、密码与用户绑定在同一事务中写入
+	_, err := global.Redis.TxPipelined(func(pipe redis.Pipeliner) error {
+		// 不公开的房间不进入房间列表
+		if room.Visibility != ctype.VisibilityUnlisted {
+			pipe.ZAdd("publicRooms", redis.Z{
+				Score:  float64(room.Created.Unix()),
+				Member: room.Code,
+			})
+		}
+		if passwordHash != "" {
+			pipe.Set(roomPasswordKey(room.Code), passwordHash, roomTTL)
+		} else {
+			pipe.Del(roomPasswordKey(room.Code))
+		}
+		pipe.Set(userRoomKey(userID), room.Code, roomTTL)
+		return nil
+	})
+	if err != nil {
+		global.Redis.Del(roomKey(room.Code))
+		return fmt.Errorf("保存房间失败: %v", err)
+	}
 	return nil
 }
 
 // GetPublicRoomByCode 获取房间详细信息
 func GetPublicRoomByCode(code string) (*model.PublicRoom, error) {
-	roomKey := "publicRoom:" + code
-	data, err := global.Redis.Get(roomKey).Result()
+	data, err := global.Redis.Get(roomKey(code)).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("房间不存在")
+			return nil, errRoomNotFound
 		}
 		return nil, fmt.Errorf("获取房间信息失败: %v", err)
 	}
@@ -64,129 +160,343 @@ func GetPublicRoomByCode(code string) (*model.PublicRoom, error) {
 	return &room, nil
 }
 
-// AddPlayerToRoom 将玩家加入房间，避免重复加入
-func AddPlayerToRoom(roomCode string, player *model.Player) error {
-	room, err := GetPublicRoomByCode(roomCode)
+// CheckRoomPassword 校验密码房间的密码，其他房间直接通过
+func CheckRoomPassword(room *model.PublicRoom, password string) error {
+	if room.Visibility != ctype.VisibilityPassword {
+		return nil
+	}
+	hash, err := global.Redis.Get(roomPasswordKey(room.Code)).Result()
 	if err != nil {
-		return err
+		return fmt.Errorf("获取房间密码失败: %v", err)
 	}
-
-	// 避免重复加入
-	for _, p := range room.Players {
-		if p.UserID == player.UserID {
-			return fmt.Errorf("玩家已在房间内")
-		}
+	if password == "" || !pwd.ComparePasswords(hash, password) {
+		return fmt.Errorf("房间密码错误")
 	}
+	return nil
+}
 
-	if len(room.Players) >= room.Capacity {
-		return fmt.Errorf("房间已满")
-	}
+// AddPlayerToRoom 将玩家加入房间并绑定用户与房间，避免重复加入，并发加入时人数上限由事务保证
+func AddPlayerToRoom(roomCode string, player *model.Player) (*model.PublicRoom, error) {
+	userKey := userRoomKey(player.UserID)
+	return watchPublicRoom(roomCode, []string{userKey}, func(tx *redis.Tx, room *model.PublicRoom, pipe redis.Pipeliner) error {
+		// 在事务内检查，避免同时加入两个房间；绑定的房间已不存在时允许加入
+		if code, _ := tx.Get(userKey).Result(); code != "" && code != roomCode {
+			if n, _ := tx.Exists(roomKey(code)).Result(); n > 0 {
+				return fmt.Errorf("已在其他房间中，请先离开")
+			}
+		}
+		// 避免重复加入
+		for _, p := range room.Players {
+			if p.UserID == player.UserID {
+				return fmt.Errorf("玩家已在房间内")
+			}
+		}
+		if room.Locked {
+			return fmt.Errorf("房间已锁定")
+		}
+		if containsUser(room.Banned, player.UserID) {
+			return fmt.Errorf("已被房主禁止加入该房间")
+		}
+		if len(room.Players) >= room.Capacity {
+			return fmt.Errorf("房间已满")
+		}
+		room.Players = append(room.Players, player)
+		pipe.Set(userKey, roomCode, roomTTL)
+		return nil
+	})
+}
 
-	room.Players = append(room.Players, player)
+// RoomQuery 房间列表的筛选条件与分页游标
+type RoomQuery struct {
+	Status  ctype.RoomStatus // 房间状态，0 表示不限
+	Mode    ctype.GameMode   // 游戏模式，为空表示不限
+	Free    bool             // 只看还有空位的房间
+	Friends []uint           // 只看有这些用户在内的房间
+	Keyword string           // 按房间名或房间码搜索，不区分大小写
+	Cursor  string           // 上一页返回的游标，为空从头开始
+	Limit   int              // 每页数量
+}
 
-	// 更新房间详细信息
-	roomData, _ := json.Marshal(room)
-	if err := global.Redis.Set("publicRoom:"+roomCode, roomData, 24*time.Hour).Err(); err != nil {
-		return fmt.Errorf("更新房间信息失败: %v", err)
+// QueryPublicRooms 按条件分页查询房间列表，按创建时间从新到旧排列。
+// 从游标处按分数分批读取，凑满一页即停止；返回本页房间、下一页游标与总数，带筛选条件时不统计总数，返回 -1。
+// 游标为“分数:房间码”，房间被删除后依然有效
+func QueryPublicRooms(q RoomQuery) ([]*model.PublicRoom, string, int64, error) {
+	maxScore := "+inf"
+	var (
+		cursorScore float64
+		cursorCode  string
+	)
+	if q.Cursor != "" {
+		score, code, ok := strings.Cut(q.Cursor, ":")
+		s, err := strconv.ParseFloat(score, 64)
+		if !ok || err != nil {
+			return nil, "", 0, fmt.Errorf("游标不合法")
+		}
+		// 包含游标分数本身，同分数的成员按字典序倒序排列，跳过不小于游标房间码的成员
+		maxScore = strconv.FormatFloat(s, 'f', -1, 64)
+		cursorScore, cursorCode = s, code
 	}
 
-	return nil
+	page := make([]*model.PublicRoom, 0, q.Limit)
+	var next string
+	for offset := int64(0); ; offset += listBatch {
+		entries, err := global.Redis.ZRevRangeByScoreWithScores("publicRooms", redis.ZRangeBy{
+			Max:    maxScore,
+			Min:    "-inf",
+			Offset: offset,
+			Count:  listBatch,
+		}).Result()
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("获取房间列表失败: %v", err)
+		}
+		rooms, err := loadRooms(zsetCodes(entries))
+		if err != nil {
+			return nil, "", 0, err
+		}
+		for i, room := range rooms {
+			z := entries[i]
+			if cursorCode != "" && z.Score == cursorScore && z.Member.(string) >= cursorCode {
+				continue
+			}
+			if room == nil || !matchRoom(room, q) {
+				continue
+			}
+			// 本页已满且还有符合条件的房间，才返回下一页游标
+			if len(page) == q.Limit {
+				return page, next, roomTotal(q), nil
+			}
+			page = append(page, room)
+			next = strconv.FormatFloat(z.Score, 'f', -1, 64) + ":" + room.Code
+		}
+		if len(entries) < listBatch {
+			return page, "", roomTotal(q), nil
+		}
+	}
 }
 
-// GetPublicRoomsList 获取所有公共房间，按创建时间排序
-func GetPublicRoomsList() ([]*model.PublicRoom, error) {
-	roomCodes, err := global.Redis.ZRevRange("publicRooms", 0, -1).Result()
+// roomTotal 房间列表的总数，只在没有筛选条件时用 ZCARD 统计，可能包含尚未清理的过期房间码
+func roomTotal(q RoomQuery) int64 {
+	if q.Status != 0 || q.Mode != "" || q.Free || len(q.Friends) > 0 || q.Keyword != "" {
+		return -1
+	}
+	n, err := global.Redis.ZCard("publicRooms").Result()
 	if err != nil {
-		return nil, fmt.Errorf("获取房间列表失败: %v", err)
+		return -1
 	}
+	return n
+}
 
-	var rooms []*model.PublicRoom
-	for _, code := range roomCodes {
-		room, err := GetPublicRoomByCode(code)
-		if err != nil {
-			continue
-		}
-		rooms = append(rooms, room)
+func zsetCodes(entries []redis.Z) []string {
+	codes := make([]string, len(entries))
+	for i, z := range entries {
+		codes[i] = z.Member.(string)
 	}
+	return codes
+}
 
+// loadRooms 分批用 pipeline 读取房间详情，结果与 codes 一一对应，已过期的房间为 nil
+func loadRooms(codes []string) ([]*model.PublicRoom, error) {
+	rooms := make([]*model.PublicRoom, len(codes))
+	for start := 0; start < len(codes); start += listBatch {
+		end := min(start+listBatch, len(codes))
+		cmds, err := global.Redis.Pipelined(func(pipe redis.Pipeliner) error {
+			for _, code := range codes[start:end] {
+				pipe.Get(roomKey(code))
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("获取房间信息失败: %v", err)
+		}
+		for i, cmd := range cmds {
+			data, err := cmd.(*redis.StringCmd).Result()
+			if err != nil {
+				continue
+			}
+			var room model.PublicRoom
+			if json.Unmarshal([]byte(data), &room) == nil {
+				rooms[start+i] = &room
+			}
+		}
+	}
 	return rooms, nil
 }
 
-// DismissPublicRoom 解散房间（仅房主可操作）
-func DismissPublicRoom(userID uint) error {
-	// 查找用户与房间的映射关系
-	roomCode, err := global.Redis.Get(fmt.Sprintf("userRoom:%d", userID)).Result()
-	if err != nil {
-		if err == redis.Nil {
-			return fmt.Errorf("房间不存在或您不是房主")
-		}
-		return fmt.Errorf("获取用户房间失败: %v", err)
+// matchRoom 房间是否满足筛选条件
+func matchRoom(room *model.PublicRoom, q RoomQuery) bool {
+	if q.Status != 0 && room.Status != q.Status {
+		return false
 	}
-	// 删除房间数据
-	if err := DeletePublicRoom(roomCode); err != nil {
-		return fmt.Errorf("解散房间失败: %v", err)
+	if q.Mode != "" && room.Mode != q.Mode {
+		return false
 	}
-	// 删除用户与房间的映射
-	if err := global.Redis.Del(fmt.Sprintf("userRoom:%d", userID)).Err(); err != nil {
-		return fmt.Errorf("解绑用户房间失败: %v", err)
+	if q.Free && (room.Locked || len(room.Players) >= room.Capacity) {
+		return false
 	}
-	return nil
+	if len(q.Friends) > 0 {
+		found := false
+		for _, p := range room.Players {
+			if containsUser(q.Friends, p.UserID) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Keyword != "" {
+		keyword := strings.ToLower(q.Keyword)
+		if !strings.Contains(strings.ToLower(room.Name), keyword) && !strings.Contains(strings.ToLower(room.Code), keyword) {
+			return false
+		}
+	}
+	return true
 }
 
-// RemovePlayerFromRoom 将玩家从房间中移除
-func RemovePlayerFromRoom(roomCode string, userID uint) error {
-	room, err := GetPublicRoomByCode(roomCode)
+// AllPublicRooms 扫描全部房间，包括不在房间列表中的 unlisted 房间
+func AllPublicRooms() ([]*model.PublicRoom, error) {
+	var codes []string
+	iter := global.Redis.Scan(0, roomKey("*"), listBatch).Iterator()
+	for iter.Next() {
+		codes = append(codes, strings.TrimPrefix(iter.Val(), roomKey("")))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("扫描房间失败: %v", err)
+	}
+	rooms, err := loadRooms(codes)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	// 查找并移除玩家
-	found := false
-	newPlayers := make([]*model.Player, 0, len(room.Players))
-	for _, p := range room.Players {
-		if p.UserID == userID {
-			found = true
-			continue
+	var list []*model.PublicRoom
+	for _, room := range rooms {
+		if room != nil {
+			list = append(list, room)
 		}
-		newPlayers = append(newPlayers, p)
 	}
-	if !found {
-		return fmt.Errorf("玩家不在房间中")
-	}
-	room.Players = newPlayers
+	return list, nil
+}
 
-	// 如果房间没人了，直接删除房间
-	if len(room.Players) == 0 {
-		return DeletePublicRoom(roomCode)
-	}
+// removeOrphanScript 房间详情不存在时才从房间列表移除房间码，检查与移除在同一脚本中原子执行，
+// 避免移除刚被 SavePublicRoomToRedis 重新分配的房间码
+var removeOrphanScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 0 then
+	return redis.call("ZREM", KEYS[1], ARGV[1])
+end
+return 0`)
 
-	// 更新房间信息
-	roomData, _ := json.Marshal(room)
-	if err := global.Redis.Set("publicRoom:"+roomCode, roomData, 24*time.Hour).Err(); err != nil {
-		return fmt.Errorf("更新房间信息失败: %v", err)
+// CleanRoomList 移除房间列表中详情已过期的房间码，返回移除的数量
+func CleanRoomList() (int, error) {
+	codes, err := global.Redis.ZRange("publicRooms", 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("获取房间列表失败: %v", err)
 	}
-
-	// 解绑用户和房间关系
-	if err := global.Redis.Del(fmt.Sprintf("userRoom:%d", userID)).Err(); err != nil {
-		return fmt.Errorf("解绑用户房间失败: %v", err)
+	var orphaned []string
+	for start := 0; start < len(codes); start += listBatch {
+		end := min(start+listBatch, len(codes))
+		cmds, err := global.Redis.Pipelined(func(pipe redis.Pipeliner) error {
+			for _, code := range codes[start:end] {
+				pipe.Exists(roomKey(code))
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("检查房间失败: %v", err)
+		}
+		for i, cmd := range cmds {
+			if cmd.(*redis.IntCmd).Val() == 0 {
+				orphaned = append(orphaned, codes[start+i])
+			}
+		}
+	}
+	// 先批量找出候选，再逐个确认并移除
+	removed := 0
+	for _, code := range orphaned {
+		n, err := removeOrphanScript.Run(global.Redis, []string{"publicRooms", roomKey(code)}, code).Int()
+		if err != nil {
+			return removed, fmt.Errorf("清理房间列表失败: %v", err)
+		}
+		removed += n
 	}
+	return removed, nil
+}
 
-	return nil
+// ClosePublicRoom 不经房主直接关闭房间并解除所有成员的绑定，返回关闭前的房间以便通知成员
+func ClosePublicRoom(code string) (*model.PublicRoom, error) {
+	return closeRoom(code, func(room *model.PublicRoom) error { return nil })
 }
 
-// DeletePublicRoom 删除公共房间
-func DeletePublicRoom(code string) error {
-	if err := global.Redis.ZRem("publicRooms", code).Err(); err != nil {
-		return err
+// closeRoom 在事务中清空房间并解绑成员，check 失败时不做修改
+func closeRoom(code string, check func(room *model.PublicRoom) error) (*model.PublicRoom, error) {
+	// 清空玩家即删除房间，所有成员的绑定在同一事务中解除
+	var members []*model.Player
+	room, err := updatePublicRoom(code, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		if err := check(room); err != nil {
+			return err
+		}
+		for _, p := range room.Players {
+			pipe.Del(userRoomKey(p.UserID))
+		}
+		members = room.Players
+		room.Players = nil
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	if err := global.Redis.Del("publicRoom:" + code).Err(); err != nil {
-		return err
+	room.Players = members
+	return room, nil
+}
+
+// DismissPublicRoom 解散房间（仅房主可操作），返回解散前的房间以便通知成员
+func DismissPublicRoom(userID uint) (*model.PublicRoom, error) {
+	// 查找用户与房间的映射关系
+	roomCode, err := global.Redis.Get(userRoomKey(userID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("房间不存在或您不是房主")
+		}
+		return nil, fmt.Errorf("获取用户房间失败: %v", err)
 	}
-	return nil
+	return closeRoom(roomCode, func(room *model.PublicRoom) error {
+		if room.OwnerID != userID {
+			return fmt.Errorf("只有房主可以解散房间")
+		}
+		return nil
+	})
+}
+
+// RemovePlayerFromRoom 将玩家从房间中移除，房主离开时转让给最早加入的玩家。
+// 返回的房间没有玩家时说明房间已被删除
+func RemovePlayerFromRoom(roomCode string, userID uint) (*model.PublicRoom, error) {
+	return updatePublicRoom(roomCode, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		// 查找并移除玩家，房间没人了会被直接删除
+		found := false
+		newPlayers := make([]*model.Player, 0, len(room.Players))
+		for _, p := range room.Players {
+			if p.UserID == userID {
+				found = true
+				continue
+			}
+			newPlayers = append(newPlayers, p)
+		}
+		if !found {
+			return fmt.Errorf("玩家不在房间中")
+		}
+		room.Players = newPlayers
+		// 有人离开后房间不再满员，取消自动开始的倒计时
+		room.AutoStartAt = 0
+		if room.OwnerID == userID && len(newPlayers) > 0 {
+			room.OwnerID = newPlayers[0].UserID
+		}
+		// 解绑用户和房间关系
+		pipe.Del(userRoomKey(userID))
+		return nil
+	})
 }
 
 func GetUserRoomCode(userID uint) (string, error) {
-	key := fmt.Sprintf("userRoom:%d", userID)
-	code, err := global.Redis.Get(key).Result()
+	code, err := global.Redis.Get(userRoomKey(userID)).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return "", fmt.Errorf("用户没有房间")
@@ -195,3 +505,185 @@ func GetUserRoomCode(userID uint) (string, error) {
 	}
 	return code, nil
 }
+
+// AllReady 房间已满且除房主外的成员都已准备
+func AllReady(room *model.PublicRoom) bool {
+	if len(room.Players) < room.Capacity {
+		return false
+	}
+	for _, p := range room.Players {
+		if p.UserID != room.OwnerID && !p.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// ToggleReady 切换成员的准备状态，房主无需准备。
+// 开启自动开始的房间在全员准备后记录预定开始时间，任何人取消准备都会清除
+func ToggleReady(code string, userID uint) (*model.PublicRoom, error) {
+	return updatePublicRoom(code, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		if room.OwnerID == userID {
+			return fmt.Errorf("房主无需准备")
+		}
+		if room.Status == ctype.Playing {
+			return fmt.Errorf("游戏进行中")
+		}
+		found := false
+		for _, p := range room.Players {
+			if p.UserID == userID {
+				p.Ready = !p.Ready
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("玩家不在房间中")
+		}
+		room.AutoStartAt = 0
+		if room.AutoStart && AllReady(room) {
+			room.AutoStartAt = time.Now().Add(AutoStartDelay).UnixMilli()
+		}
+		return nil
+	})
+}
+
+// updateOwnedRoom 修改 ownerID 所在的房间，仅房主可以操作
+func updateOwnedRoom(ownerID uint, fn func(room *model.PublicRoom, pipe redis.Pipeliner) error) (*model.PublicRoom, error) {
+	code, err := GetUserRoomCode(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return updatePublicRoom(code, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		if room.OwnerID != ownerID {
+			return fmt.Errorf("只有房主可以进行该操作")
+		}
+		return fn(room, pipe)
+	})
+}
+
+// UpdateRoomSettings 房主在等待中修改房间设置（已由调用方校验），设置变化后所有成员需要重新准备
+func UpdateRoomSettings(userID uint, settings model.RoomSettings) (*model.PublicRoom, error) {
+	return updateOwnedRoom(userID, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		if room.Status == ctype.Playing {
+			return fmt.Errorf("游戏进行中，不能修改房间设置")
+		}
+		if settings.Capacity < len(room.Players) {
+			return fmt.Errorf("人数上限不能少于当前人数 %d", len(room.Players))
+		}
+		room.RoomSettings = settings
+		unreadyAll(room)
+		return nil
+	})
+}
+
+// KickPlayer 房主将成员移出房间，ban 为 true 时该用户在房间存在期间不能再加入
+func KickPlayer(ownerID, targetID uint, ban bool) (*model.PublicRoom, error) {
+	return updateOwnedRoom(ownerID, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		if targetID == ownerID {
+			return fmt.Errorf("不能移出自己")
+		}
+		found := false
+		newPlayers := make([]*model.Player, 0, len(room.Players))
+		for _, p := range room.Players {
+			if p.UserID == targetID {
+				found = true
+				continue
+			}
+			newPlayers = append(newPlayers, p)
+		}
+		// 封禁不要求对方在房间内，可以提前拉黑
+		if !found && !ban {
+			return fmt.Errorf("玩家不在房间中")
+		}
+		room.Players = newPlayers
+		room.AutoStartAt = 0
+		if ban && !containsUser(room.Banned, targetID) {
+			room.Banned = append(room.Banned, targetID)
+		}
+		if found {
+			pipe.Del(userRoomKey(targetID))
+		}
+		return nil
+	})
+}
+
+// SetRoomLocked 房主锁定或解锁房间，锁定后不再接受新玩家
+func SetRoomLocked(ownerID uint, locked bool) (*model.PublicRoom, error) {
+	return updateOwnedRoom(ownerID, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		room.Locked = locked
+		return nil
+	})
+}
+
+// TransferOwner 房主把房主身份转让给房间内的其他成员，双方的准备状态都会清除
+func TransferOwner(ownerID, targetID uint) (*model.PublicRoom, error) {
+	return updateOwnedRoom(ownerID, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		if targetID == ownerID {
+			return fmt.Errorf("已经是房主")
+		}
+		found := false
+		for _, p := range room.Players {
+			if p.UserID == targetID {
+				found = true
+			}
+			if p.UserID == targetID || p.UserID == ownerID {
+				p.Ready = false
+			}
+		}
+		if !found {
+			return fmt.Errorf("玩家不在房间中")
+		}
+		room.OwnerID = targetID
+		room.AutoStartAt = 0
+		return nil
+	})
+}
+
+func containsUser(ids []uint, userID uint) bool {
+	for _, id := range ids {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimRoomStart 在事务中把全员已准备的房间从等待中改为游戏中，房间已在游戏中时失败，
+// 保证同一房间只有一个请求能开局。返回标记后的房间，开局失败时调用方需恢复为等待中
+func ClaimRoomStart(code string) (*model.PublicRoom, error) {
+	return updatePublicRoom(code, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		if room.Status == ctype.Playing {
+			return fmt.Errorf("游戏已在进行中")
+		}
+		if len(room.Players) != room.Capacity {
+			return fmt.Errorf("房间人数不合法，当前人数 %d", len(room.Players))
+		}
+		if !AllReady(room) {
+			return fmt.Errorf("还有玩家未准备")
+		}
+		room.Status = ctype.Playing
+		room.StartedAt = time.Now().UnixMilli()
+		unreadyAll(room)
+		return nil
+	})
+}
+
+// SetPublicRoomStatus 修改房间状态（对局结束或开局失败后恢复等待），并清除所有成员的准备状态，下一局需要重新准备。
+// 开局使用 ClaimRoomStart
+func SetPublicRoomStatus(code string, status ctype.RoomStatus) (*model.PublicRoom, error) {
+	return updatePublicRoom(code, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		room.Status = status
+		if status == ctype.Waiting {
+			room.StartedAt = 0
+		}
+		unreadyAll(room)
+		return nil
+	})
+}
+
+func unreadyAll(room *model.PublicRoom) {
+	for _, p := range room.Players {
+		p.Ready = false
+	}
+	room.AutoStartAt = 0
+}
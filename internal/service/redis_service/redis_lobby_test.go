@@ -0,0 +1,403 @@
+package redis_service
+
+import (
+	"encoding/json"
+	"fmt"
+	"plane_war/internal/global"
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+// newTestRedis 启动内存 Redis 并替换 global.Redis，测试结束后恢复
+func newTestRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	old := global.Redis
+	global.Redis = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		global.Redis.Close()
+		global.Redis = old
+	})
+	return mr
+}
+
+// newTestRoom 创建房主为 owner、其余成员为 members 的等待中房间
+func newTestRoom(t *testing.T, capacity int, owner uint, members ...uint) string {
+	room := &model.PublicRoom{
+		ID:           fmt.Sprintf("room-%d", owner),
+		OwnerID:      owner,
+		Players:      []*model.Player{{UserID: owner}},
+		Status:       ctype.Waiting,
+		Created:      time.Now(),
+		RoomSettings: model.RoomSettings{Mode: ctype.ModePvP, Capacity: capacity},
+	}
+	if err := SavePublicRoomToRedis(room, owner, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range members {
+		if _, err := AddPlayerToRoom(room.Code, &model.Player{UserID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return room.Code
+}
+
+// checkConsistent 房间成员与 userRoom 绑定一一对应，返回房间内的用户
+func checkConsistent(t *testing.T, mr *miniredis.Miniredis, code string) map[uint]bool {
+	t.Helper()
+	members := map[uint]bool{}
+	if room, err := GetPublicRoomByCode(code); err == nil {
+		for _, p := range room.Players {
+			if members[p.UserID] {
+				t.Errorf("用户 %d 在房间中出现多次", p.UserID)
+			}
+			members[p.UserID] = true
+		}
+	}
+	bound := map[uint]bool{}
+	for _, key := range mr.Keys() {
+		if !strings.HasPrefix(key, "userRoom:") {
+			continue
+		}
+		var id uint
+		fmt.Sscanf(key, "userRoom:%d", &id)
+		if v, _ := mr.Get(key); v == code {
+			bound[id] = true
+		}
+	}
+	for id := range members {
+		if !bound[id] {
+			t.Errorf("房间成员 %d 没有 userRoom 绑定", id)
+		}
+	}
+	for id := range bound {
+		if !members[id] {
+			t.Errorf("用户 %d 的 userRoom 指向房间但不在房间中", id)
+		}
+	}
+	return members
+}
+
+func TestConcurrentJoinLastSlot(t *testing.T) {
+	mr := newTestRedis(t)
+	code := newTestRoom(t, 3, 1, 2)
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = AddPlayerToRoom(code, &model.Player{UserID: uint(100 + i)})
+		}(i)
+	}
+	wg.Wait()
+
+	joined := 0
+	for _, err := range errs {
+		if err == nil {
+			joined++
+		}
+	}
+	if joined != 1 {
+		t.Fatalf("只剩一个空位，应有 1 人加入成功，实际 %d", joined)
+	}
+	members := checkConsistent(t, mr, code)
+	if len(members) != 3 || !members[1] || !members[2] {
+		t.Fatalf("原有成员丢失或人数不对: %v", members)
+	}
+}
+
+func TestConcurrentLeave(t *testing.T) {
+	mr := newTestRedis(t)
+	code := newTestRoom(t, 4, 1, 2, 3, 4)
+
+	var wg sync.WaitGroup
+	for id := uint(1); id <= 4; id++ {
+		wg.Add(1)
+		go func(id uint) {
+			defer wg.Done()
+			if _, err := RemovePlayerFromRoom(code, id); err != nil {
+				t.Errorf("用户 %d 离开失败: %v", id, err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if _, err := GetPublicRoomByCode(code); err == nil {
+		t.Fatal("成员全部离开后房间应被删除")
+	}
+	if mr.Exists(roomPasswordKey(code)) {
+		t.Error("房间删除后密码应一起删除")
+	}
+	if members, _ := mr.ZMembers("publicRooms"); len(members) != 0 {
+		t.Errorf("房间删除后应移出房间列表: %v", members)
+	}
+	checkConsistent(t, mr, code)
+}
+
+func TestConcurrentKickLeaveJoin(t *testing.T) {
+	mr := newTestRedis(t)
+	code := newTestRoom(t, 4, 1, 2, 3, 4)
+
+	var wg sync.WaitGroup
+	run := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	// 房主移出 2、3 的同时 2、3、4 主动离开，5、6 抢空位
+	removed := map[uint]int{}
+	var mu sync.Mutex
+	count := func(id uint, err error) {
+		if err == nil {
+			mu.Lock()
+			removed[id]++
+			mu.Unlock()
+		}
+	}
+	for _, id := range []uint{2, 3} {
+		id := id
+		run(func() { _, err := KickPlayer(1, id, false); count(id, err) })
+	}
+	for _, id := range []uint{2, 3, 4} {
+		id := id
+		run(func() { _, err := RemovePlayerFromRoom(code, id); count(id, err) })
+	}
+	for _, id := range []uint{5, 6} {
+		id := id
+		run(func() { AddPlayerToRoom(code, &model.Player{UserID: id}) })
+	}
+	wg.Wait()
+
+	for _, id := range []uint{2, 3, 4} {
+		if removed[id] != 1 {
+			t.Errorf("用户 %d 应恰好被移出一次，实际 %d 次", id, removed[id])
+		}
+	}
+	members := checkConsistent(t, mr, code)
+	if !members[1] {
+		t.Fatal("房主不应被移出")
+	}
+	for _, id := range []uint{2, 3, 4} {
+		if members[id] {
+			t.Errorf("用户 %d 已离开但仍在房间中", id)
+		}
+	}
+}
+
+func TestConcurrentJoinTwoRooms(t *testing.T) {
+	mr := newTestRedis(t)
+	codes := []string{newTestRoom(t, 4, 1), newTestRoom(t, 4, 2)}
+
+	// 同一用户同时加入两个房间，只能加入其中一个
+	for round := 0; round < 20; round++ {
+		var wg sync.WaitGroup
+		errs := make([]error, len(codes))
+		for i, code := range codes {
+			wg.Add(1)
+			go func(i int, code string) {
+				defer wg.Done()
+				_, errs[i] = AddPlayerToRoom(code, &model.Player{UserID: 9})
+			}(i, code)
+		}
+		wg.Wait()
+
+		joined := ""
+		for i, err := range errs {
+			if err == nil {
+				if joined != "" {
+					t.Fatalf("第 %d 轮同时加入了两个房间", round)
+				}
+				joined = codes[i]
+			}
+		}
+		if joined == "" {
+			t.Fatalf("第 %d 轮两个房间都加入失败: %v", round, errs)
+		}
+		for _, code := range codes {
+			checkConsistent(t, mr, code)
+		}
+		if _, err := RemovePlayerFromRoom(joined, 9); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConcurrentClaimRoomStart(t *testing.T) {
+	newTestRedis(t)
+	code := newTestRoom(t, 2, 1, 2)
+	if _, err := ClaimRoomStart(code); err == nil {
+		t.Fatal("成员未准备时不应开局")
+	}
+	if _, err := ToggleReady(code, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = ClaimRoomStart(code)
+		}(i)
+	}
+	wg.Wait()
+
+	claimed := 0
+	for _, err := range errs {
+		if err == nil {
+			claimed++
+		}
+	}
+	if claimed != 1 {
+		t.Fatalf("同一房间应只有 1 个请求开局成功，实际 %d", claimed)
+	}
+	room, err := GetPublicRoomByCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.Status != ctype.Playing {
+		t.Fatalf("开局后房间应为游戏中，实际 %v", room.Status)
+	}
+}
+
+func TestQueryPublicRoomsPaging(t *testing.T) {
+	newTestRedis(t)
+	// 同一秒创建的房间分数相同，翻页需按房间码区分
+	const n = 25
+	for i := uint(1); i <= n; i++ {
+		code := newTestRoom(t, 2, i)
+		if i%5 == 0 {
+			if _, err := AddPlayerToRoom(code, &model.Player{UserID: 1000 + i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		rooms, next, total, err := QueryPublicRooms(RoomQuery{Cursor: cursor, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != n {
+			t.Fatalf("没有筛选条件时总数应为 %d，实际 %d", n, total)
+		}
+		for _, room := range rooms {
+			if seen[room.Code] {
+				t.Fatalf("房间 %s 在多页中重复出现", room.Code)
+			}
+			seen[room.Code] = true
+		}
+		if next == "" {
+			break
+		}
+		if pages > n {
+			t.Fatal("翻页没有结束")
+		}
+		cursor = next
+	}
+	if len(seen) != n {
+		t.Fatalf("翻完所有页应得到 %d 个房间，实际 %d", n, len(seen))
+	}
+
+	rooms, next, total, err := QueryPublicRooms(RoomQuery{Free: true, Limit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != n-n/5 || next != "" || total != -1 {
+		t.Fatalf("筛选有空位的房间应得到 %d 个、没有下一页且不统计总数，实际 %d 个、next=%q、total=%d", n-n/5, len(rooms), next, total)
+	}
+}
+
+func TestFindRoomByInput(t *testing.T) {
+	mr := newTestRedis(t)
+	code := newTestRoom(t, 2, 1)
+	// 旧版本的小写十六进制房间码
+	legacy := &model.PublicRoom{Code: "a1b2c3", OwnerID: 2, Players: []*model.Player{{UserID: 2}}}
+	data, _ := json.Marshal(legacy)
+	mr.Set(roomKey(legacy.Code), string(data))
+
+	for input, want := range map[string]string{
+		" " + strings.ToLower(code) + " ": code,
+		"a1b2c3":                          "a1b2c3",
+		" a1b2c3\n":                       "a1b2c3",
+	} {
+		room, err := FindRoomByInput(input)
+		if err != nil {
+			t.Fatalf("输入 %q 应找到房间: %v", input, err)
+		}
+		if room.Code != want {
+			t.Fatalf("输入 %q 应找到房间 %s，实际 %s", input, want, room.Code)
+		}
+	}
+	if _, err := FindRoomByInput("zzzzzz"); err != errRoomNotFound {
+		t.Fatalf("不存在的房间码应返回房间不存在，实际 %v", err)
+	}
+}
+
+func TestLeaveCancelsAutoStart(t *testing.T) {
+	newTestRedis(t)
+	code := newTestRoom(t, 3, 1, 2, 3)
+	if _, err := updatePublicRoom(code, func(room *model.PublicRoom, pipe redis.Pipeliner) error {
+		room.AutoStart = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ToggleReady(code, 2)
+	room, err := ToggleReady(code, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.AutoStartAt == 0 {
+		t.Fatal("全员准备后应开始自动开始倒计时")
+	}
+	room, err = RemovePlayerFromRoom(code, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.AutoStartAt != 0 {
+		t.Fatalf("成员离开后应取消自动开始，实际 auto_start_at=%d", room.AutoStartAt)
+	}
+}
+
+func TestCleanRoomList(t *testing.T) {
+	mr := newTestRedis(t)
+	live := newTestRoom(t, 2, 1)
+	expired := newTestRoom(t, 2, 2)
+	mr.Del(roomKey(expired))
+	mr.ZAdd("publicRooms", 1, "GONE22")
+
+	n, err := CleanRoomList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("应移除 2 个过期房间码，实际 %d", n)
+	}
+	members, _ := mr.ZMembers("publicRooms")
+	if len(members) != 1 || members[0] != live {
+		t.Fatalf("房间列表应只剩 %s，实际 %v", live, members)
+	}
+
+	// 房间码在检查之后被重新分配，脚本确认时详情已存在，不应移除
+	mr.ZAdd("publicRooms", 2, "AGAIN2")
+	mr.Set(roomKey("AGAIN2"), "{}")
+	if n, err := removeOrphanScript.Run(global.Redis, []string{"publicRooms", roomKey("AGAIN2")}, "AGAIN2").Int(); err != nil || n != 0 {
+		t.Fatalf("已重新分配的房间码不应移除: n=%d err=%v", n, err)
+	}
+}
@@ -0,0 +1,47 @@
+package redis_service
+
+import (
+	"crypto/rand"
+	"math/big"
+	"plane_war/internal/model"
+	"strings"
+)
+
+const (
+	// roomCodeAlphabet 房间码字符集，去掉了容易混淆的 0/O、1/I/L
+	roomCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+	// roomCodeLength 房间码长度
+	roomCodeLength = 6
+	// maxCodeRetries 房间码冲突时的最大重试次数
+	maxCodeRetries = 10
+)
+
+// generateRoomCode 随机生成房间码
+func generateRoomCode() string {
+	var b strings.Builder
+	size := big.NewInt(int64(len(roomCodeAlphabet)))
+	for i := 0; i < roomCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			panic(err)
+		}
+		b.WriteByte(roomCodeAlphabet[n.Int64()])
+	}
+	return b.String()
+}
+
+// NormalizeRoomCode 规范化用户输入的房间码，房间码不区分大小写
+func NormalizeRoomCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// FindRoomByInput 按用户输入的房间码查找房间，先按规范化后的房间码查找；
+// 旧版本生成的房间码是小写十六进制，找不到时再按原样查找，返回的房间 Code 为实际保存的房间码
+func FindRoomByInput(input string) (*model.PublicRoom, error) {
+	raw := strings.TrimSpace(input)
+	room, err := GetPublicRoomByCode(NormalizeRoomCode(raw))
+	if err == errRoomNotFound && raw != NormalizeRoomCode(raw) {
+		room, err = GetPublicRoomByCode(raw)
+	}
+	return room, err
+}
@@ -0,0 +1,50 @@
+package jwts
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"plane_war/internal/global"
+	"time"
+)
+
+// InviteClaims 房间邀请，持有者可以跳过密码直接加入房间
+type InviteClaims struct {
+	RoomCode string `json:"room_code"`
+	RoomID   string `json:"room_id"` // 房间码可能被新房间复用，加入时核对房间ID
+	Inviter  uint   `json:"inviter"`
+	jwt.RegisteredClaims
+}
+
+// GenInviteToken 生成指定房间的邀请，expire 后失效
+func GenInviteToken(roomCode, roomID string, inviter uint, expire time.Duration) (string, error) {
+	MySecret = []byte(global.Config.Auth.AccessSecret)
+	claims := InviteClaims{
+		RoomCode: roomCode,
+		RoomID:   roomID,
+		Inviter:  inviter,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expire)),
+			Issuer:    "plane_war",
+			Subject:   "invite",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(MySecret)
+}
+
+// ParseInviteToken 解析邀请，过期或签名不对时返回错误
+func ParseInviteToken(tokenString string) (*InviteClaims, error) {
+	MySecret = []byte(global.Config.Auth.AccessSecret)
+	token, err := jwt.ParseWithClaims(tokenString, &InviteClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return MySecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 登录 token 使用同一密钥签名，靠 subject 区分
+	if claims, ok := token.Claims.(*InviteClaims); ok && token.Valid && claims.Subject == "invite" && claims.RoomCode != "" {
+		return claims, nil
+	}
+	return nil, errors.New("invalid invite")
+}
@@ -2,25 +2,42 @@ package ws
 
 import (
 	"encoding/json"
-	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"plane_war/internal/global"
 	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
 	"plane_war/internal/service/game"
 	"plane_war/internal/service/match"
+	"plane_war/internal/service/redis_service"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Client struct {
-	Player *model.Player //关联玩家信息
-	Send   chan []byte   //消息通道
+	Player  *model.Player              //关联玩家信息
+	Send    chan []byte                //消息通道
+	room    atomic.Pointer[model.Room] //开局时绑定的房间
+	latency latency                    //延迟估计，只在 ReadPump 中读写
+	ping    atomic.Int64               //最近的延迟估计，供其他协程读取
+	sendMu  sync.RWMutex               //大厅事件等会从其他协程推送，关闭通道时需要互斥
+	closed  bool                       //Send 是否已关闭
 }
 
+// clients 玩家ID到连接的索引，开局时据此绑定房间
+var clients sync.Map
+
+// userClients 用户ID到连接的索引，用于推送大厅事件
+var userClients sync.Map
+
 func NewClientWithPlayer(p *model.Player) *Client {
-	return &Client{
+	c := &Client{
 		Player: p,
 		Send:   make(chan []byte, 256),
 	}
+	// 游戏循环通过 Player.Send 推送，WritePump 是连接唯一的写者
+	p.Send = c.send
+	return c
 }
 
 // Hub 管理客户端
@@ -41,19 +58,62 @@ func NewHub() *Hub {
 		Unregister: make(chan *Client),
 	}
 	go h.Run()
+	go runMatchmaker()
 	return h
 }
 
+// rescanInterval 重新检查匹配队列的间隔，与延迟差按秒放宽保持一致
+const rescanInterval = time.Second
+
+// runMatchmaker 定期重新检查匹配队列，让排队较久、放宽了延迟差的玩家也能开局
+func runMatchmaker() {
+	ticker := time.NewTicker(rescanInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for _, room := range match.MatchQueueInstance.Rescan(now) {
+			startMatchedRoom(room)
+		}
+	}
+}
+
+// startMatchedRoom 启动匹配凑成的房间并通知房间内的玩家
+func startMatchedRoom(room *model.Room) {
+	game.AddRoom(room)
+	BindRoom(room)
+
+	// 按机型设置玩家位置、血量、上下标识
+	game.InitRoom(room)
+
+	// 发送匹配成功消息给双方
+	state := map[string]interface{}{
+		"type":    "match_success",
+		"room_id": room.ID,
+		"players": room.Players,
+		"arena":   game.ArenaOf(room),
+	}
+	SendToRoom(room, state)
+
+	global.Log.Printf("匹配成功，房间id ：%s", room.ID)
+	game.StartRoomLoop(room)
+}
+
 func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.Register:
 			h.Clients[client] = true
+			clients.Store(client.Player.ID, client)
+			if client.Player.UserID != 0 {
+				userClients.Store(client.Player.UserID, client)
+			}
 			global.Log.Printf("new player connected :%s", client.Player.Name)
 		case client := <-h.Unregister:
 			if _, ok := h.Clients[client]; ok {
 				delete(h.Clients, client)
-				close(client.Send)
+				clients.CompareAndDelete(client.Player.ID, client)
+				userClients.CompareAndDelete(client.Player.UserID, client)
+				client.unsubscribeRoomList()
+				client.closeSend()
 				global.Log.Printf("player disconnected : %s", client.Player.Name)
 			}
 		case message := <-h.Broadcast:
@@ -61,7 +121,7 @@ func (h *Hub) Run() {
 				select {
 				case client.Send <- message:
 				default:
-					close(client.Send)
+					client.closeSend()
 					delete(h.Clients, client)
 				}
 			}
@@ -71,14 +131,21 @@ func (h *Hub) Run() {
 
 // --------消息处理--------
 type Message struct {
-	Action string `json:"action"`
-	X      int    `json:"x,omitempty"`
-	Y      int    `json:"y,omitempty"`
+	Action  string `json:"action"`
+	X       int    `json:"x,omitempty"`
+	Y       int    `json:"y,omitempty"`
+	Plane   string `json:"plane,omitempty"`   //选择的机型
+	Weapon  string `json:"weapon,omitempty"`  //选择的武器
+	Ability string `json:"ability,omitempty"` //释放的技能
+	Mode    string `json:"mode,omitempty"`    //匹配模式：pvp（默认）/coop
+	Size    int    `json:"size,omitempty"`    //合作模式队伍人数 1~4
+	Arena   string `json:"arena,omitempty"`   //匹配的场地，为空使用默认场地
+	Tick    uint64 `json:"tick,omitempty"`    //开火时客户端正在渲染的 tick，用于延迟补偿
+	Time    int64  `json:"time,omitempty"`    //ping 时客户端的本地时间（毫秒），原样返回用于估算时钟偏差
+	// ServerTime pong 时原样返回服务端 ping 中的时间戳，用于测量往返延迟
+	ServerTime int64 `json:"server_time,omitempty"`
 }
 
-var RoomMap = make(map[string]*model.Room)
-var RoomLock sync.Mutex
-
 func (c *Client) ReadPump() {
 	defer func() {
 		HubInstance.Unregister <- c
@@ -96,67 +163,122 @@ func (c *Client) ReadPump() {
 			continue
 		}
 		switch m.Action {
+		case "select_plane":
+			if c.selectPlane(m) {
+				c.sendJSON(map[string]interface{}{
+					"type":   "plane_selected",
+					"plane":  c.Player.Plane,
+					"weapon": c.Player.Loadout,
+				})
+			}
+
 		case "match":
-			room := match.MatchQueueInstance.AddPlayer(c.Player)
+			if m.Plane != "" || m.Weapon != "" {
+				if !c.selectPlane(m) {
+					continue
+				}
+			}
+			if m.Arena != "" && !game.ArenaExists(m.Arena) {
+				c.sendJSON(map[string]interface{}{
+					"type": "error",
+					"msg":  "场地不存在",
+				})
+				continue
+			}
+			var room *model.Room
+			if ctype.GameMode(m.Mode) == ctype.ModeCoop {
+				if m.Size < 1 || m.Size > 4 {
+					c.sendJSON(map[string]interface{}{
+						"type": "error",
+						"msg":  "合作模式人数必须为 1~4",
+					})
+					continue
+				}
+				room = match.MatchQueueInstance.AddCoopPlayer(c.Player, m.Size, m.Arena, c.Ping())
+			} else {
+				room = match.MatchQueueInstance.AddPvPPlayer(c.Player, m.Arena, c.Ping())
+			}
 			if room != nil {
-				RoomLock.Lock()
-				RoomMap[room.ID] = room
-				RoomLock.Unlock()
+				startMatchedRoom(room)
+			}
+		case "move":
+			if room := c.currentRoom(); room != nil {
+				room.Lock.Lock()
+				if game.InputAllowed(room) {
+					game.RequestMove(room, c.Player, m.X, m.Y)
+				}
+				room.Lock.Unlock()
+			}
 
-				// 设置玩家位置、血量、上下标识
+		case "shoot":
+			if room := c.currentRoom(); room != nil {
 				room.Lock.Lock()
-				room.Players[0].X = 100
-				room.Players[0].Y = 50
-				room.Players[0].HP = 100
-				room.Players[0].Position = "top"
-
-				room.Players[1].X = 100
-				room.Players[1].Y = 500
-				room.Players[1].HP = 100
-				room.Players[1].Position = "bottom"
+				if game.InputAllowed(room) {
+					game.RequestShoot(c.Player, m.Tick)
+				}
 				room.Lock.Unlock()
+			}
 
-				// 发送匹配成功消息给双方
-				state := map[string]interface{}{
-					"type":    "match_success",
-					"room_id": room.ID,
-					"players": room.Players,
+		case "ability":
+			if room := c.currentRoom(); room != nil {
+				var err error
+				room.Lock.Lock()
+				if game.InputAllowed(room) {
+					err = game.RequestAbility(c.Player, m.Ability)
 				}
-				data, _ := json.Marshal(state)
-				for _, p := range room.Players {
-					p.Conn.WriteMessage(websocket.TextMessage, data)
+				room.Lock.Unlock()
+				if err != nil {
+					c.sendJSON(map[string]interface{}{
+						"type": "error",
+						"msg":  err.Error(),
+					})
 				}
-
-				global.Log.Printf("匹配成功，房间id ：%s", room.ID)
-				game.StartRoomLoop(room)
 			}
-		case "move":
-			room := findPlayerRoom(c.Player.ID)
-			if room != nil {
+
+		case "reload":
+			if room := c.currentRoom(); room != nil {
 				room.Lock.Lock()
-				c.Player.X = m.X
-				c.Player.Y = m.Y
+				if game.InputAllowed(room) {
+					game.RequestReload(c.Player, time.Now())
+				}
 				room.Lock.Unlock()
 			}
 
-		case "shoot":
-			room := findPlayerRoom(c.Player.ID)
-			if room != nil {
+		case "ping":
+			c.sendJSON(map[string]interface{}{
+				"type":        "pong",
+				"time":        m.Time,
+				"server_time": time.Now().UnixMilli(),
+			})
+
+		case "pong":
+			c.handlePong(m.ServerTime)
+
+		case "subscribe_rooms":
+			rooms, next, _, err := redis_service.QueryPublicRooms(redis_service.RoomQuery{Limit: roomListPage})
+			if err != nil {
+				c.sendJSON(map[string]interface{}{
+					"type": "error",
+					"msg":  err.Error(),
+				})
+				continue
+			}
+			c.subscribeRoomList(rooms, next)
+
+		case "unsubscribe_rooms":
+			c.unsubscribeRoomList()
+
+		case "surrender", "pause", "resume", "draw":
+			if room := c.currentRoom(); room != nil {
 				room.Lock.Lock()
-				b := &model.Bullet{
-					ID:     uuid.New().String(),
-					X:      c.Player.X + 22, //子弹从飞机中心射出
-					Y:      c.Player.Y,
-					Owner:  c.Player.ID,
-					Damage: 10,
-				}
-				if c.Player.Position == "top" {
-					b.Speed = -10 //向下
-				} else {
-					b.Speed = 10 //向上
-				}
-				room.Bullets = append(room.Bullets, b)
+				err := game.RequestMatchAction(room, c.Player, m.Action)
 				room.Lock.Unlock()
+				if err != nil {
+					c.sendJSON(map[string]interface{}{
+						"type": "error",
+						"msg":  err.Error(),
+					})
+				}
 			}
 
 		default:
@@ -167,29 +289,124 @@ func (c *Client) ReadPump() {
 }
 
 func (c *Client) WritePump() {
-	defer c.Player.Conn.Close()
-	for msg := range c.Send {
-		err := c.Player.Conn.WriteMessage(websocket.TextMessage, msg)
-		if err != nil {
-			global.Log.Println("write error:", err)
-			break
+	ticker := time.NewTicker(pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.Player.Conn.Close()
+	}()
+	for {
+		select {
+		case msg, ok := <-c.Send:
+			if !ok {
+				return
+			}
+			err := c.Player.Conn.WriteMessage(websocket.TextMessage, msg)
+			if err != nil {
+				global.Log.Println("write error:", err)
+				return
+			}
+			global.Log.Printf("发给玩家 %s: %s", c.Player.ID, msg)
+		case <-ticker.C:
+			// 定时测量延迟，客户端需原样回复 server_time
+			data, _ := json.Marshal(map[string]interface{}{
+				"type":        "ping",
+				"server_time": time.Now().UnixMilli(),
+			})
+			if err := c.Player.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				global.Log.Println("write error:", err)
+				return
+			}
 		}
-		global.Log.Printf("发给玩家 %s: %s", c.Player.ID, msg)
 	}
 }
 
-// 根据玩家ID找到房间
-func findPlayerRoom(playerID string) *model.Room {
-	RoomLock.Lock()
-	defer RoomLock.Unlock()
-	for _, r := range RoomMap {
-		for _, p := range r.Players {
-			if p.ID == playerID {
-				return r
-			}
+// selectPlane 校验并保存玩家选择的机型与武器，校验失败时回复错误消息。
+// 对局中游戏循环会读取玩家的机型，不允许更换
+func (c *Client) selectPlane(m Message) bool {
+	if c.currentRoom() != nil {
+		c.sendJSON(map[string]interface{}{
+			"type": "error",
+			"msg":  "对局中不能更换机型",
+		})
+		return false
+	}
+	plane, err := game.ValidateLoadout(m.Plane, m.Weapon)
+	if err != nil {
+		c.sendJSON(map[string]interface{}{
+			"type": "error",
+			"msg":  err.Error(),
+		})
+		return false
+	}
+	c.Player.Plane = plane
+	c.Player.Loadout = m.Weapon
+	return true
+}
+
+// sendJSON 通过发送通道给客户端推送消息，连接已关闭时丢弃
+func (c *Client) sendJSON(v interface{}) {
+	data, _ := json.Marshal(v)
+	c.send(data)
+}
+
+// send 把消息放入发送通道，队列已满或连接已关闭时丢弃
+func (c *Client) send(data []byte) {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.Send <- data:
+	default:
+		global.Log.Printf("玩家 %s 发送队列已满，丢弃消息", c.Player.ID)
+	}
+}
+
+// closeSend 关闭发送通道，WritePump 随之退出
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
+}
+
+// SendToRoom 给房间内全部玩家推送消息
+func SendToRoom(room *model.Room, v interface{}) {
+	data, _ := json.Marshal(v)
+	for _, p := range room.Players {
+		if v, ok := clients.Load(p.ID); ok {
+			v.(*Client).send(data)
+		}
+	}
+}
+
+// BindRoom 将房间绑定到房间内玩家的连接，之后的操作不再查找房间
+func BindRoom(room *model.Room) {
+	for _, p := range room.Players {
+		if v, ok := clients.Load(p.ID); ok {
+			v.(*Client).room.Store(room)
 		}
 	}
-	return nil
+}
+
+// currentRoom 客户端所在的房间，未绑定时按玩家索引查找，房间归档后解除绑定
+func (c *Client) currentRoom() *model.Room {
+	room := c.room.Load()
+	if room == nil {
+		room = game.FindPlayerRoom(c.Player.ID)
+		if room == nil {
+			return nil
+		}
+		c.room.Store(room)
+	}
+	if game.RoomClosed(room) {
+		c.room.CompareAndSwap(room, nil)
+		return nil
+	}
+	return room
 }
 
 // 发送完整房间状态
@@ -203,18 +420,13 @@ func sendRoomState(room *model.Room) {
 		"bullets": room.Bullets,
 	}
 
-	data, _ := json.Marshal(state)
-	for _, p := range room.Players {
-		p.Conn.WriteMessage(websocket.TextMessage, data)
-	}
+	SendToRoom(room, state)
 }
 
 // 根据 UserID 查找客户端
 func FindClientByUserID(userID uint) *Client {
-	for client := range HubInstance.Clients {
-		if client.Player.UserID == userID {
-			return client
-		}
+	if v, ok := userClients.Load(userID); ok {
+		return v.(*Client)
 	}
 	return nil
 }
@@ -0,0 +1,61 @@
+package ws
+
+import (
+	"math"
+	"time"
+)
+
+// pingInterval 服务端主动测量延迟的间隔
+const pingInterval = 2 * time.Second
+
+// maxRTTSample 超过该值的往返时间视为无效采样（客户端回填了错误的时间戳等）
+const maxRTTSample = 10 * time.Second
+
+// latency 连接的延迟估计，只在 ReadPump 中更新
+type latency struct {
+	rtt     float64 // 平滑后的往返时间（毫秒）
+	jitter  float64 // 相邻采样差值的平滑均值（毫秒）
+	last    float64 // 上一次采样
+	samples int
+}
+
+// update 按 RFC 6298 的方式平滑 RTT，按 RFC 3550 的方式估计抖动
+func (l *latency) update(sample float64) {
+	if l.samples == 0 {
+		l.rtt = sample
+	} else {
+		l.rtt += (sample - l.rtt) / 8
+		l.jitter += (math.Abs(sample-l.last) - l.jitter) / 16
+	}
+	l.last = sample
+	l.samples++
+}
+
+// handlePong 处理客户端对服务端 ping 的回复，更新延迟估计并同步到玩家信息
+func (c *Client) handlePong(serverTime int64) {
+	sample := time.Since(time.UnixMilli(serverTime))
+	if sample < 0 || sample > maxRTTSample {
+		return
+	}
+	c.latency.update(float64(sample) / float64(time.Millisecond))
+	ping := int(math.Round(c.latency.rtt))
+	jitter := int(math.Round(c.latency.jitter))
+	c.ping.Store(int64(ping))
+
+	// 已绑定房间时玩家信息由游戏循环在房间锁内读取，写入也要持有房间锁；
+	// 未绑定房间时只有本连接会修改玩家信息
+	room := c.currentRoom()
+	if room != nil {
+		room.Lock.Lock()
+	}
+	c.Player.Ping = ping
+	c.Player.Jitter = jitter
+	if room != nil {
+		room.Lock.Unlock()
+	}
+}
+
+// Ping 平滑后的往返延迟（毫秒），尚未测量时为 0
+func (c *Client) Ping() int {
+	return int(c.ping.Load())
+}
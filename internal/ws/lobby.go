@@ -0,0 +1,90 @@
+package ws
+
+import (
+	"plane_war/internal/model"
+	"plane_war/internal/model/ctype"
+	"sync"
+)
+
+// 大厅事件，推送给房间内所有成员
+const (
+	MemberJoined    = "member_joined"
+	MemberLeft      = "member_left"
+	ReadyChanged    = "ready_changed"
+	SettingsChanged = "settings_changed"
+	Dismissed       = "dismissed"
+	GameStarting    = "game_starting"
+	GameEnded       = "game_ended"
+	MemberKicked    = "member_kicked"
+	LockChanged     = "lock_changed"
+	OwnerChanged    = "owner_changed"
+)
+
+// roomListPage 订阅房间列表时推送的第一页房间数，与 HTTP 房间列表的默认每页数量一致
+const roomListPage = 20
+
+// roomListSubscribers 订阅了房间列表的连接
+var roomListSubscribers sync.Map
+
+// subscribeRoomList 订阅房间列表并推送第一页，next 为下一页游标，之后房间的变化会推送 room_list_update
+func (c *Client) subscribeRoomList(rooms []*model.PublicRoom, next string) {
+	roomListSubscribers.Store(c, struct{}{})
+	c.sendJSON(map[string]interface{}{
+		"type":  "room_list",
+		"rooms": rooms,
+		"next":  next,
+	})
+}
+
+func (c *Client) unsubscribeRoomList() {
+	roomListSubscribers.Delete(c)
+}
+
+// PublishLobbyEvent 把大厅事件推送给房间成员与房间列表的订阅者，userID 为事件涉及的用户（加入、离开或被移出的成员，新房主等）。
+// 房间没有成员时说明房间已删除，订阅者会收到 removed
+func PublishLobbyEvent(room *model.PublicRoom, event string, userID uint) {
+	msg := map[string]interface{}{
+		"type":    "lobby_event",
+		"event":   event,
+		"user_id": userID,
+		"room":    room,
+	}
+	for _, p := range room.Players {
+		if c := FindClientByUserID(p.UserID); c != nil {
+			c.sendJSON(msg)
+		}
+	}
+	// 离开或被移出的成员已不在房间里，单独通知
+	if event == MemberLeft || event == MemberKicked {
+		if c := FindClientByUserID(userID); c != nil {
+			c.sendJSON(msg)
+		}
+	}
+
+	if event == Dismissed {
+		room = &model.PublicRoom{Code: room.Code, Visibility: room.Visibility}
+	}
+	PublishRoomListUpdate(room)
+}
+
+// PublishRoomListUpdate 把房间的变化推送给房间列表的订阅者，房间没有成员时推送 removed
+func PublishRoomListUpdate(room *model.PublicRoom) {
+	// 不公开的房间不在列表中
+	if room.Visibility == ctype.VisibilityUnlisted {
+		return
+	}
+	update := map[string]interface{}{
+		"type": "room_list_update",
+		"room": room,
+	}
+	if len(room.Players) == 0 {
+		update = map[string]interface{}{
+			"type":    "room_list_update",
+			"removed": room.Code,
+		}
+	}
+	roomListSubscribers.Range(func(k, _ interface{}) bool {
+		k.(*Client).sendJSON(update)
+		return true
+	})
+}
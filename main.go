@@ -20,6 +20,8 @@ func main() {
 		db := core.InitMysql()
 		err := db.AutoMigrate(
 			&model.User{},
+			&model.MatchResult{},
+			&model.MatchPlayer{},
 		)
 		if err != nil {
 			fmt.Println("表结构生成失败", err)